@@ -0,0 +1,23 @@
+package identity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// FakeProvider 本地模拟的身份提供方，不访问网络
+// 同一个 code 总是得到同一个 openid，code 为 "invalid" 时模拟登录失败
+type FakeProvider struct{}
+
+// Code2Session 根据 code 生成固定的 openid
+func (FakeProvider) Code2Session(ctx context.Context, code string) (*Session, error) {
+	if code == "" || code == "invalid" {
+		return nil, ErrInvalidCode
+	}
+	sum := sha256.Sum256([]byte(code))
+	return &Session{
+		OpenID:     "fake_" + hex.EncodeToString(sum[:8]),
+		SessionKey: hex.EncodeToString(sum[8:24]),
+	}, nil
+}
@@ -0,0 +1,36 @@
+// Package identity 封装小程序登录的身份提供方，便于在微信和本地模拟实现之间切换
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Session 用 code 换取到的小程序会话
+type Session struct {
+	OpenID     string
+	UnionID    string
+	SessionKey string
+}
+
+// Provider 身份提供方
+type Provider interface {
+	// Code2Session 用小程序 wx.login 返回的 code 换取 openid 和会话密钥
+	Code2Session(ctx context.Context, code string) (*Session, error)
+}
+
+// ErrInvalidCode code 无效或已过期
+var ErrInvalidCode = errors.New("invalid login code")
+
+// New 按名称创建身份提供方，name 为 wechat 或 fake
+func New(name, appID, appSecret string) (Provider, error) {
+	switch name {
+	case "", "wechat":
+		return NewWeChatProvider(appID, appSecret), nil
+	case "fake":
+		return FakeProvider{}, nil
+	default:
+		return nil, fmt.Errorf("unknown identity provider %q", name)
+	}
+}
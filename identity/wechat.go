@@ -0,0 +1,81 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WeChatProvider 微信小程序 code2Session 登录
+type WeChatProvider struct {
+	AppID     string
+	AppSecret string
+	BaseURL   string // 默认 https://api.weixin.qq.com
+	Client    *http.Client
+}
+
+// NewWeChatProvider 创建微信身份提供方
+func NewWeChatProvider(appID, appSecret string) *WeChatProvider {
+	return &WeChatProvider{
+		AppID:     appID,
+		AppSecret: appSecret,
+		BaseURL:   "https://api.weixin.qq.com",
+		Client:    &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// code2SessionResponse 微信接口返回结构
+type code2SessionResponse struct {
+	OpenID     string `json:"openid"`
+	SessionKey string `json:"session_key"`
+	UnionID    string `json:"unionid"`
+	ErrCode    int    `json:"errcode"`
+	ErrMsg     string `json:"errmsg"`
+}
+
+// Code2Session 调用 /sns/jscode2session
+func (p *WeChatProvider) Code2Session(ctx context.Context, code string) (*Session, error) {
+	if code == "" {
+		return nil, ErrInvalidCode
+	}
+
+	query := url.Values{}
+	query.Set("appid", p.AppID)
+	query.Set("secret", p.AppSecret)
+	query.Set("js_code", code)
+	query.Set("grant_type", "authorization_code")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"/sns/jscode2session?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result code2SessionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode code2session response: %w", err)
+	}
+	switch result.ErrCode {
+	case 0:
+	case 40029, 40163: // code 无效、code 已被使用
+		return nil, ErrInvalidCode
+	default:
+		return nil, fmt.Errorf("code2session error %d: %s", result.ErrCode, result.ErrMsg)
+	}
+	if result.OpenID == "" {
+		return nil, ErrInvalidCode
+	}
+
+	return &Session{
+		OpenID:     result.OpenID,
+		UnionID:    result.UnionID,
+		SessionKey: result.SessionKey,
+	}, nil
+}
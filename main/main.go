@@ -15,17 +15,111 @@ func main() {
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/LookAt-MeNow/flowers/router"
     "github.com/LookAt-MeNow/flowers/sql"
 )
 
 func main() {
+	configPath := flag.String("config", "", "配置文件路径，不指定时依次查找 ./config/mysql.yml 和 /home/www/flowers/config/mysql.yml")
+	importCategories := flag.String("import-categories", "", "从 JSON 文件导入分类树后退出，例如 data/categories.json")
+	importHome := flag.String("import-home", "", "从目录中的 JSON 文件导入首页轮播图、导航和楼层后退出，例如 data")
+	flag.Parse()
+
     // 加载配置
-	cfg := sql.LoadConfig()
+	cfg, err := sql.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
 	// 初始化数据库
 	db := sql.InitDB(cfg)
+	// 表结构迁移：flowers migrate [up|down [n]|status]
+	if flag.Arg(0) == "migrate" {
+		runMigrate(db, flag.Args()[1:])
+		return
+	}
+	// 内存库每次启动都是空库，直接执行迁移
+	if cfg.InMemory() {
+		if _, err := sql.Migrate(db); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+	}
+	// 表结构落后时拒绝启动，避免新代码读写不存在的列
+	if err := sql.CheckSchema(db); err != nil {
+		log.Fatalf("%v", err)
+	}
+	// 一次性导入分类和首页数据
+	if *importCategories != "" {
+		n, err := sql.ImportCategories(db, *importCategories)
+		if err != nil {
+			log.Fatalf("import categories: %v", err)
+		}
+		log.Printf("imported %d categories", n)
+	}
+	if *importHome != "" {
+		n, err := sql.ImportHomeContent(db, *importHome)
+		if err != nil {
+			log.Fatalf("import home content: %v", err)
+		}
+		log.Printf("imported %d home content items", n)
+	}
+	if *importCategories != "" || *importHome != "" {
+		return
+	}
+	// 图片存储
+	store, err := router.NewStorage(cfg)
+	if err != nil {
+		log.Fatalf("init storage: %v", err)
+	}
+	// 已上架的商家鲜花同步到公共商品目录
+	if err := router.SyncFlowerCatalog(db, store); err != nil {
+		log.Printf("sync flower catalog failed: %v", err)
+	}
+	// 支付渠道
+	pay, err := router.NewPaymentProvider(cfg)
+	if err != nil {
+		log.Fatalf("init payment provider: %v", err)
+	}
+	// 超时未支付订单自动取消
+	router.StartOrderExpiryWorker(db, time.Minute)
+	// 渠道处理中的退款定时查询结果
+	router.StartRefundSyncWorker(db, pay, time.Minute)
+	// 清理没有图片记录引用的上传文件
+	router.StartImageSweeper(db, store, time.Hour)
 	// 初始化路由
-	r := router.SetupRouter(db)
+	r := router.SetupRouter(db, cfg, store, pay)
 	// 启动服务
-	r.Run(":8080") // 默认监听 0.0.0.0:8080
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:      r,
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout) * time.Second,
+	}
+	go func() {
+		log.Printf("server listening on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("listen: %v", err)
+		}
+	}()
+
+	// 收到退出信号后等待正在处理的请求完成
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("server shutdown: %v", err)
+	}
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/LookAt-MeNow/flowers/sql"
+	"gorm.io/gorm"
+)
+
+// runMigrate 执行 migrate 子命令
+//
+//	migrate [up]      执行全部未执行的迁移
+//	migrate down [n]  回滚最近 n 个迁移，默认 1 个
+//	migrate status    查看迁移执行情况
+func runMigrate(db *gorm.DB, args []string) {
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		applied, err := sql.Migrate(db)
+		for _, m := range applied {
+			log.Printf("migrated %d %s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Printf("schema is up to date (version %d)", sql.LatestVersion())
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("migrate down: invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		rolledBack, err := sql.Rollback(db, steps)
+		for _, m := range rolledBack {
+			log.Printf("rolled back %d %s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("migrate down: %v", err)
+		}
+	case "status":
+		status, err := sql.MigrationStatus(db)
+		if err != nil {
+			log.Fatalf("migrate status: %v", err)
+		}
+		for _, s := range status {
+			applied := "pending"
+			if !s.AppliedAt.IsZero() {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		log.Fatalf("unknown migrate action %q, expected up, down or status", action)
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Address 买家收货地址
+type Address struct {
+	gorm.Model
+	UserID        uint   `gorm:"index;not null" json:"user_id"`
+	RecipientName string `gorm:"size:50;not null" json:"recipient_name"`
+	Phone         string `gorm:"size:20;not null" json:"phone"`
+	ProvinceCode  string `gorm:"size:6;not null" json:"province_code"`
+	CityCode      string `gorm:"size:6;not null" json:"city_code"`
+	DistrictCode  string `gorm:"size:6;not null" json:"district_code"`
+	Province      string `gorm:"size:50" json:"province"` // 名称按编码从地区数据中取得
+	City          string `gorm:"size:50" json:"city"`
+	District      string `gorm:"size:50" json:"district"`
+	Detail        string `gorm:"size:200;not null" json:"detail"` // 街道、门牌号
+	IsDefault     bool   `json:"is_default"`
+}
+
+// FullAddress 完整地址，直辖市的"市辖区"等占位名称不拼入；
+// 东莞市等不设区县的地级市，区县与城市同名，只拼一次
+func (a Address) FullAddress() string {
+	city := a.City
+	if city == "市辖区" || city == "县" || strings.HasSuffix(city, "直辖县级行政区划") {
+		city = ""
+	}
+	district := a.District
+	if district == city {
+		district = ""
+	}
+	return a.Province + city + district + a.Detail
+}
+
+// Region 省市区数据，编码为国家统计局行政区划代码
+type Region struct {
+	Code     string   `json:"code"`
+	Name     string   `json:"name"`
+	Children []Region `json:"children,omitempty"`
+}
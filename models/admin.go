@@ -5,6 +5,7 @@ import "gorm.io/gorm"
 type Admin struct {
 	gorm.Model
 	Username string `gorm:"uniqueIndex;size:50;not null"`
-	Password string `gorm:"size:255;not null"`
-	Role     string `gorm:"size:20;default:'admin'"`
+	Password string `gorm:"size:255;not null"` // bcrypt 哈希
+	Salt     string `gorm:"size:64"`           // 旧版 MD5 密码的盐值，升级为 bcrypt 后清空
+	Role     string `gorm:"size:20;default:'operator'"` // 角色名，对应 Role.Name
 }
\ No newline at end of file
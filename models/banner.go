@@ -2,8 +2,10 @@ package models
 
 // 轮播图数据结构
 type Banner struct { 
-    ImageSrc     string `json:"image_src"`
-    OpenType     string `json:"open_type"`
+    ID           uint   `gorm:"primaryKey" json:"id"`
+    ImageSrc     string `gorm:"size:255;not null" json:"image_src"`
+    OpenType     string `gorm:"size:20" json:"open_type"`
     GoodsID      int    `json:"goods_id"`
-    NavigatorURL string `json:"navigator_url"`
+    NavigatorURL string `gorm:"size:255" json:"navigator_url"`
+    ContentSchedule
 }
\ No newline at end of file
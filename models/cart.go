@@ -0,0 +1,17 @@
+package models
+
+import "gorm.io/gorm"
+
+// CartItem 购物车条目
+// GoodsID 和 FlowerID 二选一：GoodsID 指向平台商品(可选 AttrID 规格)，FlowerID 指向商家鲜花，
+// 鲜花有规格时 SKUID 必填
+type CartItem struct {
+	gorm.Model
+	UserID   uint `gorm:"index;not null" json:"user_id"`
+	GoodsID  uint `gorm:"index" json:"goods_id"`
+	AttrID   uint `json:"attr_id"`
+	FlowerID uint `gorm:"index" json:"flower_id"`
+	SKUID    uint `gorm:"column:sku_id" json:"sku_id"`
+	Quantity int  `gorm:"not null" json:"quantity"`
+	Selected bool `json:"selected"`
+}
@@ -1,20 +1,36 @@
 package models
 
-//分类数据结构
+//分类数据结构，首页导航入口，保存在 nav_items 表
 type Category struct {
-    Name         string `json:"name"`
-    ImageSrc     string `json:"image_src"`
-    OpenType     string `json:"open_type,omitempty"`
-    NavigatorURL string `json:"navigator_url,omitempty"`
+    ID           uint   `gorm:"primaryKey" json:"id"`
+    Name         string `gorm:"size:50;not null" json:"name"`
+    ImageSrc     string `gorm:"size:255;not null" json:"image_src"`
+    OpenType     string `gorm:"size:20" json:"open_type,omitempty"`
+    NavigatorURL string `gorm:"size:255" json:"navigator_url,omitempty"`
+    ContentSchedule
 }
 
-////分类页面结构
+// 自定义表名
+func (Category) TableName() string {
+    return "nav_items"
+}
+
+////分类页面结构，保存在 categories 表，Children 由查询结果组装
 type CategoryTree struct {
-    CatID      int           `json:"cat_id"`
-    CatName    string        `json:"cat_name"`
-    CatPid     int           `json:"cat_pid"`
-    CatLevel   int           `json:"cat_level"`
-    CatDeleted bool          `json:"cat_deleted"`
-    CatIcon    string        `json:"cat_icon"`
-    Children   []CategoryTree `json:"children,omitempty"`
-}
\ No newline at end of file
+    CatID      int            `gorm:"primaryKey;column:cat_id" json:"cat_id"`
+    CatName    string         `gorm:"column:cat_name;size:50;not null" json:"cat_name"`
+    CatPid     int            `gorm:"column:cat_pid;index" json:"cat_pid"`
+    CatLevel   int            `gorm:"column:cat_level" json:"cat_level"`
+    CatDeleted bool           `gorm:"column:cat_deleted;index" json:"cat_deleted"`
+    CatIcon    string         `gorm:"column:cat_icon;size:255" json:"cat_icon"`
+    CatSort    int            `gorm:"column:cat_sort" json:"-"` // 同级排序，越小越靠前
+    Children   []CategoryTree `gorm:"-" json:"children,omitempty"`
+}
+
+// 自定义表名
+func (CategoryTree) TableName() string {
+    return "categories"
+}
+
+// MaxCategoryLevel 分类最大层级，从 0 开始，即最多三级分类
+const MaxCategoryLevel = 2
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// ContentSchedule 首页内容的排序、启用状态和展示时间窗口
+type ContentSchedule struct {
+	Sort    int        `gorm:"index" json:"sort"` // 越小越靠前
+	Enabled bool       `json:"enabled"`
+	StartAt *time.Time `json:"start_at"` // 为空表示立即开始
+	EndAt   *time.Time `json:"end_at"`   // 为空表示不结束
+}
@@ -0,0 +1,36 @@
+package models
+
+import "gorm.io/gorm"
+
+// DeliverySetting 商家配送设置
+type DeliverySetting struct {
+	gorm.Model
+	MerchantID     uint   `gorm:"uniqueIndex;not null" json:"merchant_id"`
+	DailyCapacity  int    `json:"daily_capacity"`                     // 每天最多配送订单数，0 表示不限
+	SameDayCutoff  string `gorm:"size:5" json:"same_day_cutoff"`      // 当日配送截单时间 HH:MM，为空表示不接当日单
+	MaxAdvanceDays int    `gorm:"default:30" json:"max_advance_days"` // 最多可提前预订的天数
+}
+
+// DeliverySlot 配送时段
+type DeliverySlot struct {
+	gorm.Model
+	MerchantID uint   `gorm:"index;not null" json:"merchant_id"`
+	StartTime  string `gorm:"size:5;not null" json:"start_time"` // HH:MM
+	EndTime    string `gorm:"size:5;not null" json:"end_time"`   // HH:MM
+	Capacity   int    `json:"capacity"`                          // 每天该时段最多订单数，0 表示不限
+	Enabled    bool   `json:"enabled"`
+	Sort       int    `json:"sort"`
+}
+
+// Label 时段展示文字，例如 09:00-12:00
+func (s DeliverySlot) Label() string {
+	return s.StartTime + "-" + s.EndTime
+}
+
+// DeliveryBlackout 不配送的日期
+type DeliveryBlackout struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	MerchantID uint   `gorm:"uniqueIndex:idx_blackout_merchant_date;not null" json:"merchant_id"`
+	Date       string `gorm:"uniqueIndex:idx_blackout_merchant_date;size:10;not null" json:"date"` // YYYY-MM-DD
+	Reason     string `gorm:"size:100" json:"reason"`
+}
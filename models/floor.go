@@ -2,21 +2,26 @@ package models
 
 // 楼层标题数据结构
 type FloorTitle struct {
-    Name     string `json:"name"`
-    ImageSrc string `json:"image_src"`
+    Name     string `gorm:"size:50" json:"name"`
+    ImageSrc string `gorm:"size:255" json:"image_src"`
 }
 
 // 楼层商品数据结构
 type FloorProduct struct {
-    Name         string `json:"name"`
-    ImageSrc     string `json:"image_src"`
-    ImageWidth   string `json:"image_width"`
-    OpenType     string `json:"open_type"`
-    NavigatorURL string `json:"navigator_url"`
+    ID           uint   `gorm:"primaryKey" json:"id"`
+    FloorID      uint   `gorm:"index;not null" json:"-"`
+    Name         string `gorm:"size:50" json:"name"`
+    ImageSrc     string `gorm:"size:255;not null" json:"image_src"`
+    ImageWidth   string `gorm:"size:10" json:"image_width"`
+    OpenType     string `gorm:"size:20" json:"open_type"`
+    NavigatorURL string `gorm:"size:255" json:"navigator_url"`
+    Sort         int    `json:"sort"`
 }
 
 // 楼层数据结构
 type Floor struct {
-    FloorTitle  FloorTitle     `json:"floor_title"`
-    ProductList []FloorProduct `json:"product_list"`
+    ID          uint           `gorm:"primaryKey" json:"id"`
+    FloorTitle  FloorTitle     `gorm:"embedded;embeddedPrefix:title_" json:"floor_title"`
+    ProductList []FloorProduct `gorm:"foreignKey:FloorID" json:"product_list"`
+    ContentSchedule
 }
\ No newline at end of file
@@ -11,11 +11,71 @@ type Flower struct {
     Description string         `gorm:"type:text"`
     Status      int            `gorm:"default:1"` // 1-上架, 0-下架
     Images      []FlowerImage  `gorm:"foreignKey:FlowerID" json:"images"`
+    Options     []FlowerOption `gorm:"foreignKey:FlowerID" json:"options,omitempty"` // 规格维度，没有规格时为空
+    SKUs        []FlowerSKU    `gorm:"foreignKey:FlowerID" json:"skus,omitempty"`
 }
 
 // FlowerImage 鲜花图片模型
 type FlowerImage struct {
     gorm.Model
     FlowerID uint   `gorm:"index;not null"`
-    Path     string `gorm:"size:255;not null"`
+    Path     string `gorm:"size:255;not null"` // 存储中的 key，例如 flowers/xxx.jpg
+    URL      string `gorm:"-"`                 // 访问地址，返回前由存储根据 Path 生成
+    Sort     int    `gorm:"not null;default:0"` // 展示顺序，第一张为封面
+
+    // 800/400/200 三种尺寸及其 webp 版本在存储中的 key，旧数据没有时使用原图
+    BigPath     string `gorm:"size:255" json:"-"`
+    MidPath     string `gorm:"size:255" json:"-"`
+    SmaPath     string `gorm:"size:255" json:"-"`
+    BigWebpPath string `gorm:"size:255" json:"-"`
+    MidWebpPath string `gorm:"size:255" json:"-"`
+    SmaWebpPath string `gorm:"size:255" json:"-"`
+
+    // 各尺寸的访问地址，字段名与 GoodsPicture 一致
+    PicsBig     string `gorm:"-" json:"pics_big"`
+    PicsMid     string `gorm:"-" json:"pics_mid"`
+    PicsSma     string `gorm:"-" json:"pics_sma"`
+    PicsBigWebp string `gorm:"-" json:"pics_big_webp"`
+    PicsMidWebp string `gorm:"-" json:"pics_mid_webp"`
+    PicsSmaWebp string `gorm:"-" json:"pics_sma_webp"`
+}
+
+// SetRendition 记录某个尺寸(big/mid/sma)的 key
+func (img *FlowerImage) SetRendition(name, path, webpPath string) {
+    switch name {
+    case "big":
+        img.BigPath, img.BigWebpPath = path, webpPath
+    case "mid":
+        img.MidPath, img.MidWebpPath = path, webpPath
+    case "sma":
+        img.SmaPath, img.SmaWebpPath = path, webpPath
+    }
+}
+
+// Keys 图片在存储中的全部文件
+func (img FlowerImage) Keys() []string {
+    var keys []string
+    for _, key := range []string{img.Path, img.BigPath, img.MidPath, img.SmaPath, img.BigWebpPath, img.MidWebpPath, img.SmaWebpPath} {
+        if key != "" {
+            keys = append(keys, key)
+        }
+    }
+    return keys
+}
+
+// ResolveURLs 用 url 把各个 key 转换为访问地址，缺少的尺寸使用原图
+func (img *FlowerImage) ResolveURLs(url func(key string) string) {
+    orOriginal := func(key string) string {
+        if key == "" {
+            key = img.Path
+        }
+        return url(key)
+    }
+    img.URL = url(img.Path)
+    img.PicsBig = orOriginal(img.BigPath)
+    img.PicsMid = orOriginal(img.MidPath)
+    img.PicsSma = orOriginal(img.SmaPath)
+    img.PicsBigWebp = orOriginal(img.BigWebpPath)
+    img.PicsMidWebp = orOriginal(img.MidWebpPath)
+    img.PicsSmaWebp = orOriginal(img.SmaWebpPath)
 }
\ No newline at end of file
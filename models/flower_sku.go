@@ -0,0 +1,43 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// SKUSpecSeparator 规格值之间的分隔符，规格值中不能包含
+const SKUSpecSeparator = "/"
+
+// FlowerOption 鲜花的规格维度，例如 支数: 11支/19支/33支，包装: 简约/豪华
+type FlowerOption struct {
+	ID       uint     `gorm:"primaryKey" json:"id"`
+	FlowerID uint     `gorm:"index;not null" json:"flower_id"`
+	Name     string   `gorm:"size:20;not null" json:"name"`
+	Values   []string `gorm:"serializer:json;type:text" json:"values"`
+	Sort     int      `gorm:"not null;default:0" json:"sort"` // 维度顺序，与 FlowerSKU.Values 的顺序一致
+}
+
+// FlowerSKU 规格组合，有自己的价格、库存和图片
+// 鲜花有 SKU 时，Flower.Price 为最低价，Flower.Stock 为全部 SKU 库存之和
+type FlowerSKU struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	FlowerID  uint      `gorm:"uniqueIndex:idx_flower_sku_spec;not null" json:"flower_id"`
+	Spec      string    `gorm:"uniqueIndex:idx_flower_sku_spec;size:100;not null" json:"spec"` // 规格值按维度顺序连接，例如 19支/豪华
+	Values    []string  `gorm:"serializer:json;type:text" json:"values"`
+	Price     float64   `gorm:"type:decimal(10,2);not null" json:"price"`
+	Stock     int       `gorm:"not null" json:"stock"`
+	ImageID   uint      `json:"image_id"`       // 鲜花的某张图片，为 0 或图片已删除时使用封面
+	Image     string    `gorm:"-" json:"image"` // 图片访问地址，返回前生成
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 自定义表名
+func (FlowerSKU) TableName() string {
+	return "flower_skus"
+}
+
+// SKUSpec 由各维度的规格值生成 Spec
+func SKUSpec(values []string) string {
+	return strings.Join(values, SKUSpecSeparator)
+}
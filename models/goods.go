@@ -17,6 +17,8 @@ type Goods struct {
 	UpdTime         time.Time `gorm:"autoUpdateTime;column:upd_time" json:"upd_time"`
 	IsPromote       bool      `gorm:"type:tinyint(1);column:is_promote" json:"is_promote"`
 	HotNumber       uint      `gorm:"column:hot_number" json:"hot_number"`
+	FlowerID        uint      `gorm:"index;column:flower_id" json:"flower_id,omitempty"`     // 由商家鲜花同步而来时对应的鲜花ID
+	MerchantID      uint      `gorm:"column:merchant_id" json:"merchant_id,omitempty"`       // 平台商品为 0
 }
 
 //------------------------------------------------------------------------
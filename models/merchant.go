@@ -4,13 +4,35 @@ import (
 	"gorm.io/gorm"
 )
 
+// 商家状态
+const (
+	MerchantStatusDisabled = 0 // 禁用（已暂停）
+	MerchantStatusNormal   = 1 // 正常
+	MerchantStatusPending  = 2 // 待审核
+	MerchantStatusRejected = 3 // 审核未通过
+)
+
 type Merchant struct {
 	gorm.Model
-	Username  string    `gorm:"uniqueIndex;size:50;not null"`
-	Password  string    `gorm:"size:255;not null"`
-	ShopName  string    `gorm:"size:100;not null"`
-	Email     string    `gorm:"uniqueIndex;size:100;not null"`
-	Phone     string    `gorm:"size:20;not null"`
-	Address   string    `gorm:"size:255"`
-	Status    int       `gorm:"default:1"` // 1-正常, 0-禁用
-}
\ No newline at end of file
+	Username     string    `gorm:"uniqueIndex;size:50;not null"`
+	Password     string    `gorm:"size:255;not null"` // bcrypt 哈希
+	Salt         string    `gorm:"size:64"`           // 旧版 MD5 密码的盐值，升级为 bcrypt 后清空
+	ShopName     string    `gorm:"size:100;not null"`
+	Email        string    `gorm:"uniqueIndex;size:100;not null"`
+	Phone        string    `gorm:"size:20;not null"`
+	Address      string    `gorm:"size:255"`
+	Status       int       `gorm:"default:1"` // 1-正常, 0-禁用, 2-待审核, 3-审核未通过
+	StatusReason string    `gorm:"size:255"`  // 最近一次审核/禁用的原因
+	TokenVersion uint      `gorm:"default:0"` // 令牌版本，禁用时递增使已签发的令牌失效
+}
+
+// MerchantStatusLog 商家状态变更记录
+type MerchantStatusLog struct {
+	gorm.Model
+	MerchantID uint   `gorm:"index;not null" json:"merchant_id"`
+	AdminID    uint   `gorm:"index;not null" json:"admin_id"`
+	Action     string `gorm:"size:20;not null" json:"action"` // approve/reject/suspend/reactivate
+	FromStatus int    `json:"from_status"`
+	ToStatus   int    `json:"to_status"`
+	Reason     string `gorm:"size:255" json:"reason"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 订单状态
+const (
+	OrderStatusPendingPayment = "pending_payment" // 待支付
+	OrderStatusPaid           = "paid"            // 已支付
+	OrderStatusPreparing      = "preparing"       // 备货中
+	OrderStatusDelivering     = "delivering"      // 配送中
+	OrderStatusCompleted      = "completed"       // 已完成
+	OrderStatusCancelled      = "cancelled"       // 已取消
+	OrderStatusRefunded       = "refunded"        // 已退款
+)
+
+// OrderTransitions 订单允许的状态流转，未列出的流转一律拒绝
+var OrderTransitions = map[string][]string{
+	OrderStatusPendingPayment: {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:           {OrderStatusPreparing, OrderStatusRefunded},
+	OrderStatusPreparing:      {OrderStatusDelivering, OrderStatusRefunded},
+	OrderStatusDelivering:     {OrderStatusCompleted, OrderStatusRefunded},
+	OrderStatusCompleted:      {},
+	OrderStatusCancelled:      {},
+	OrderStatusRefunded:       {},
+}
+
+// CanTransitionOrder 判断订单能否从 from 流转到 to
+func CanTransitionOrder(from, to string) bool {
+	for _, next := range OrderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Order 订单
+type Order struct {
+	gorm.Model
+	OrderNo     string     `gorm:"uniqueIndex;size:32;not null" json:"order_no"`
+	UserID      uint       `gorm:"index;not null" json:"user_id"`
+	MerchantID  uint       `gorm:"index;not null" json:"merchant_id"` // 平台商品订单为 0
+	Status      string     `gorm:"index;size:20;not null" json:"status"`
+	TotalAmount float64    `gorm:"type:decimal(10,2);not null" json:"total_amount"`
+	ItemCount   int        `gorm:"not null" json:"item_count"`
+	Remark      string     `gorm:"size:255" json:"remark"`
+	ExpiresAt   *time.Time `gorm:"index" json:"expires_at"` // 支付截止时间，超时未支付自动取消并释放库存
+
+	// 配送信息
+	DeliveryDate     string `gorm:"index;size:10" json:"delivery_date"` // YYYY-MM-DD
+	DeliverySlotID   uint   `json:"delivery_slot_id"`
+	DeliverySlot     string `gorm:"size:11" json:"delivery_slot"` // 下单时的时段快照，例如 09:00-12:00
+	RecipientName    string `gorm:"size:50" json:"recipient_name"`
+	RecipientPhone   string `gorm:"size:20" json:"recipient_phone"`
+	RecipientAddress string `gorm:"size:255" json:"recipient_address"`
+	CardMessage      string `gorm:"size:500" json:"card_message"` // 贺卡留言
+
+	PaidAt      *time.Time  `json:"paid_at"`
+	CompletedAt *time.Time  `json:"completed_at"`
+	CancelledAt *time.Time  `json:"cancelled_at"`
+	Items       []OrderItem `gorm:"foreignKey:OrderID" json:"items"`
+}
+
+// OrderItem 订单商品，名称、价格、图片为下单时的快照
+type OrderItem struct {
+	ID        uint    `gorm:"primaryKey" json:"id"`
+	OrderID   uint    `gorm:"index;not null" json:"order_id"`
+	GoodsID   uint    `json:"goods_id"`
+	AttrID    uint    `json:"attr_id"`
+	FlowerID  uint    `json:"flower_id"`
+	SKUID     uint    `gorm:"column:sku_id" json:"sku_id"`
+	Name      string  `gorm:"size:255;not null" json:"name"`
+	AttrValue string  `gorm:"size:100" json:"attr_value"`
+	Image     string  `gorm:"size:255" json:"image"`
+	Price     float64 `gorm:"type:decimal(10,2);not null" json:"price"`
+	Quantity  int     `gorm:"not null" json:"quantity"`
+	Subtotal  float64 `gorm:"type:decimal(10,2);not null" json:"subtotal"`
+}
+
+// OrderStatusHistory 订单状态流转记录
+type OrderStatusHistory struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	OrderID      uint      `gorm:"index;not null" json:"order_id"`
+	FromStatus   string    `gorm:"size:20" json:"from_status"` // 创建订单时为空
+	ToStatus     string    `gorm:"size:20;not null" json:"to_status"`
+	OperatorType string    `gorm:"size:20;not null" json:"operator_type"` // user/merchant/admin/system
+	OperatorID   uint      `json:"operator_id"`
+	Remark       string    `gorm:"size:255" json:"remark"`
+	CreatedAt    time.Time `json:"created_at"`
+}
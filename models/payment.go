@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 支付记录状态
+const (
+	PaymentStatusPending   = "pending"   // 已发起，等待支付结果
+	PaymentStatusSuccess   = "success"   // 支付成功
+	PaymentStatusFailed    = "failed"    // 支付失败
+	PaymentStatusRefunding = "refunding" // 已发起退款，等待渠道处理
+	PaymentStatusRefunded  = "refunded"  // 已退款
+	// 渠道退款关闭或异常，需到商户平台人工处理
+	PaymentStatusRefundFailed = "refund_failed"
+)
+
+// Payment 订单支付记录，一个订单对应一条
+type Payment struct {
+	gorm.Model
+	OrderID       uint       `gorm:"uniqueIndex;not null" json:"order_id"`
+	OrderNo       string     `gorm:"index;size:32;not null" json:"order_no"`
+	Provider      string     `gorm:"size:20;not null" json:"provider"`
+	PrepayID      string     `gorm:"size:64" json:"prepay_id"`
+	TransactionID string     `gorm:"size:64" json:"transaction_id"`
+	Amount        float64    `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Status        string     `gorm:"size:20;not null" json:"status"`
+	PaidAt        *time.Time `json:"paid_at"`
+	RefundNo      string     `gorm:"size:64" json:"refund_no"`
+	RefundID      string     `gorm:"size:64" json:"refund_id"`
+	RefundedAt    *time.Time `json:"refunded_at"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "gorm.io/gorm"
+
+// 权限编码
+const (
+	PermAdminManage     = "admin:manage"     // 管理员和角色管理
+	PermMerchantView    = "merchant:view"    // 查看商家
+	PermMerchantDisable = "merchant:disable" // 审核、禁用商家
+	PermFlowerModerate  = "flower:moderate"  // 鲜花内容审核
+	PermContentEdit     = "content:edit"     // 首页内容和分类编辑
+	PermOrderRefund     = "order:refund"     // 订单退款
+	PermOrderFulfill    = "order:fulfill"    // 平台商品订单备货、配送
+)
+
+// 内置角色
+const (
+	RoleSuperAdmin = "super_admin" // 超级管理员，拥有全部权限
+	RoleOperator   = "operator"    // 运营
+	RoleAuditor    = "auditor"     // 审核员
+)
+
+// Permission 权限
+type Permission struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Code string `gorm:"uniqueIndex;size:50;not null" json:"code"`
+	Name string `gorm:"size:50;not null" json:"name"`
+}
+
+// Role 管理员角色
+type Role struct {
+	gorm.Model
+	Name        string       `gorm:"uniqueIndex;size:20;not null" json:"name"`
+	Description string       `gorm:"size:100" json:"description"`
+	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions"`
+}
+
+// DefaultPermissions 系统内置权限
+var DefaultPermissions = []Permission{
+	{Code: PermAdminManage, Name: "管理员和角色管理"},
+	{Code: PermMerchantView, Name: "查看商家"},
+	{Code: PermMerchantDisable, Name: "审核和禁用商家"},
+	{Code: PermFlowerModerate, Name: "鲜花内容审核"},
+	{Code: PermContentEdit, Name: "首页内容和分类编辑"},
+	{Code: PermOrderRefund, Name: "订单退款"},
+	{Code: PermOrderFulfill, Name: "平台订单发货"},
+}
+
+// DefaultRoles 系统内置角色及其权限
+var DefaultRoles = []struct {
+	Name        string
+	Description string
+	Permissions []string
+}{
+	{RoleSuperAdmin, "超级管理员", []string{PermAdminManage, PermMerchantView, PermMerchantDisable, PermFlowerModerate, PermContentEdit, PermOrderRefund, PermOrderFulfill}},
+	{RoleOperator, "运营", []string{PermMerchantView, PermMerchantDisable, PermFlowerModerate, PermContentEdit, PermOrderRefund, PermOrderFulfill}},
+	{RoleAuditor, "审核员", []string{PermMerchantView, PermFlowerModerate}},
+}
@@ -0,0 +1,15 @@
+package models
+
+import "gorm.io/gorm"
+
+// User 小程序买家
+type User struct {
+	gorm.Model
+	OpenID     string `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	UnionID    string `gorm:"index;size:64" json:"-"`
+	SessionKey string `gorm:"size:128" json:"-"`
+	Nickname   string `gorm:"size:50" json:"nickname"`
+	Avatar     string `gorm:"size:255" json:"avatar"`
+	Phone      string `gorm:"size:20" json:"phone"`
+	Status     int    `gorm:"default:1" json:"status"` // 1-正常, 0-禁用
+}
@@ -0,0 +1,274 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 模拟支付结果
+const (
+	MockModeSuccess = "success" // 立即支付成功并回调
+	MockModeFail    = "fail"    // 支付失败并回调
+	MockModeDelay   = "delay"   // 延迟 Delay 后支付成功并回调
+	MockModeManual  = "manual"  // 不自动回调，由 Complete 触发
+)
+
+// 模拟退款结果
+const (
+	MockRefundSuccess    = "success"    // 退款立即成功
+	MockRefundProcessing = "processing" // 退款处理中，由 CompleteRefund 完成
+)
+
+// MockConfig 模拟支付配置
+type MockConfig struct {
+	Mode      string
+	Delay     time.Duration
+	Secret    string // 回调 HMAC 签名密钥
+	NotifyURL string // 为空时不发送回调，只能通过 Query 获取结果
+	Refund    string // 退款结果，默认 success
+}
+
+// Mock 本地模拟支付渠道，不访问网络
+// 回调使用 HMAC-SHA256 签名，格式与 VerifyNotify 对应
+type Mock struct {
+	cfg    MockConfig
+	client *http.Client
+
+	mu           sync.Mutex
+	transactions map[string]*Transaction
+	refunds      map[string]*RefundResult
+}
+
+// mockNotify 模拟回调的报文
+type mockNotify struct {
+	OrderNo       string `json:"order_no"`
+	TransactionID string `json:"transaction_id"`
+	State         string `json:"state"`
+	Amount        int64  `json:"amount"`
+	PaidAt        int64  `json:"paid_at"`
+}
+
+// NewMock 创建模拟支付渠道
+func NewMock(cfg MockConfig) *Mock {
+	if cfg.Mode == "" {
+		cfg.Mode = MockModeSuccess
+	}
+	if cfg.Refund == "" {
+		cfg.Refund = MockRefundSuccess
+	}
+	return &Mock{
+		cfg:          cfg,
+		client:       &http.Client{Timeout: 5 * time.Second},
+		transactions: make(map[string]*Transaction),
+		refunds:      make(map[string]*RefundResult),
+	}
+}
+
+// Name 渠道名称
+func (m *Mock) Name() string { return "mock" }
+
+// CreatePrepay 记录待支付交易，并按模式异步发送回调
+func (m *Mock) CreatePrepay(ctx context.Context, req PrepayRequest) (*PrepayResult, error) {
+	if req.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+
+	m.mu.Lock()
+	m.transactions[req.OrderNo] = &Transaction{
+		OrderNo: req.OrderNo,
+		State:   StateNotPay,
+		Amount:  req.Amount,
+	}
+	m.mu.Unlock()
+
+	switch m.cfg.Mode {
+	case MockModeSuccess:
+		go m.Complete(req.OrderNo, StateSuccess)
+	case MockModeFail:
+		go m.Complete(req.OrderNo, StatePayError)
+	case MockModeDelay:
+		time.AfterFunc(m.cfg.Delay, func() { m.Complete(req.OrderNo, StateSuccess) })
+	}
+
+	prepayID := "mock_" + req.OrderNo
+	return &PrepayResult{
+		PrepayID: prepayID,
+		Params: map[string]string{
+			"timeStamp": strconv.FormatInt(time.Now().Unix(), 10),
+			"nonceStr":  randomNonce(),
+			"package":   "prepay_id=" + prepayID,
+			"signType":  "MOCK",
+			"paySign":   "mock",
+		},
+	}, nil
+}
+
+// Complete 把交易置为指定状态并发送回调
+func (m *Mock) Complete(orderNo, state string) error {
+	m.mu.Lock()
+	tx, ok := m.transactions[orderNo]
+	if ok {
+		tx.State = state
+		if state == StateSuccess {
+			tx.TransactionID = "mock_tx_" + orderNo
+			tx.PaidAt = time.Now()
+		}
+	}
+	var snapshot Transaction
+	if ok {
+		snapshot = *tx
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("mock transaction %s not found", orderNo)
+	}
+	if m.cfg.NotifyURL == "" {
+		return nil
+	}
+	if err := m.sendNotify(snapshot); err != nil {
+		log.Printf("mock payment notify %s failed: %v", orderNo, err)
+		return err
+	}
+	return nil
+}
+
+func (m *Mock) sendNotify(tx Transaction) error {
+	notify := mockNotify{
+		OrderNo:       tx.OrderNo,
+		TransactionID: tx.TransactionID,
+		State:         tx.State,
+		Amount:        tx.Amount,
+	}
+	if !tx.PaidAt.IsZero() {
+		notify.PaidAt = tx.PaidAt.Unix()
+	}
+	body, err := json.Marshal(notify)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, m.cfg.NotifyURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Mock-Signature", m.signature(body))
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notify status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Query 查询模拟交易
+func (m *Mock) Query(ctx context.Context, orderNo string) (*Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx, ok := m.transactions[orderNo]
+	if !ok {
+		return &Transaction{OrderNo: orderNo, State: StateNotPay}, nil
+	}
+	snapshot := *tx
+	return &snapshot, nil
+}
+
+// Refund 模拟退款，按 Refund 配置立即成功或进入处理中
+// 同一退款单号重复申请返回同一笔退款
+func (m *Mock) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if refund, ok := m.refunds[req.RefundNo]; ok {
+		snapshot := *refund
+		return &snapshot, nil
+	}
+	tx, ok := m.transactions[req.OrderNo]
+	if ok && tx.State != StateSuccess {
+		return nil, fmt.Errorf("mock transaction %s is %s", req.OrderNo, tx.State)
+	}
+	if ok {
+		tx.State = StateRefund
+	}
+
+	refund := &RefundResult{RefundID: "mock_refund_" + req.RefundNo, Status: RefundSuccess}
+	if m.cfg.Refund == MockRefundProcessing {
+		refund.Status = RefundProcessing
+	}
+	m.refunds[req.RefundNo] = refund
+	snapshot := *refund
+	return &snapshot, nil
+}
+
+// CompleteRefund 把处理中的退款置为指定状态
+func (m *Mock) CompleteRefund(refundNo, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	refund, ok := m.refunds[refundNo]
+	if !ok {
+		return fmt.Errorf("mock refund %s not found", refundNo)
+	}
+	refund.Status = status
+	return nil
+}
+
+// QueryRefund 查询模拟退款
+func (m *Mock) QueryRefund(ctx context.Context, refundNo string) (*RefundResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	refund, ok := m.refunds[refundNo]
+	if !ok {
+		return nil, fmt.Errorf("mock refund %s not found", refundNo)
+	}
+	snapshot := *refund
+	return &snapshot, nil
+}
+
+// VerifyNotify 校验 HMAC 签名并解析回调
+func (m *Mock) VerifyNotify(r *http.Request) (*Transaction, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	expected := m.signature(body)
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Mock-Signature"))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var notify mockNotify
+	if err := json.Unmarshal(body, &notify); err != nil {
+		return nil, err
+	}
+	tx := &Transaction{
+		OrderNo:       notify.OrderNo,
+		TransactionID: notify.TransactionID,
+		State:         notify.State,
+		Amount:        notify.Amount,
+	}
+	if notify.PaidAt > 0 {
+		tx.PaidAt = time.Unix(notify.PaidAt, 0)
+	}
+	return tx, nil
+}
+
+func (m *Mock) signature(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(m.cfg.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
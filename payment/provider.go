@@ -0,0 +1,84 @@
+// Package payment 定义支付渠道接口，提供微信支付 v3 和本地模拟两种实现
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// 交易状态，取值与微信支付 trade_state 一致
+const (
+	StateSuccess  = "SUCCESS"  // 支付成功
+	StateNotPay   = "NOTPAY"   // 未支付
+	StateClosed   = "CLOSED"   // 已关闭
+	StateRefund   = "REFUND"   // 转入退款
+	StatePayError = "PAYERROR" // 支付失败
+)
+
+// 退款状态，取值与微信支付退款 status 一致
+const (
+	RefundSuccess    = "SUCCESS"    // 退款成功
+	RefundProcessing = "PROCESSING" // 退款处理中
+	RefundClosed     = "CLOSED"     // 退款关闭
+	RefundAbnormal   = "ABNORMAL"   // 退款异常
+)
+
+// ErrInvalidSignature 回调或响应签名校验失败
+var ErrInvalidSignature = errors.New("invalid payment signature")
+
+// PrepayRequest 下单请求，金额单位为分
+type PrepayRequest struct {
+	OrderNo     string
+	Description string
+	Amount      int64
+	OpenID      string
+	ExpiresAt   time.Time
+}
+
+// PrepayResult 预支付结果，Params 直接交给小程序 wx.requestPayment
+type PrepayResult struct {
+	PrepayID string
+	Params   map[string]string
+}
+
+// Transaction 交易信息，金额单位为分
+type Transaction struct {
+	OrderNo       string
+	TransactionID string
+	State         string
+	Amount        int64
+	PaidAt        time.Time
+}
+
+// RefundRequest 退款请求，金额单位为分
+type RefundRequest struct {
+	OrderNo  string
+	RefundNo string
+	Amount   int64 // 退款金额
+	Total    int64 // 原订单金额
+	Reason   string
+}
+
+// RefundResult 退款结果
+type RefundResult struct {
+	RefundID string
+	Status   string // SUCCESS / PROCESSING / CLOSED / ABNORMAL
+}
+
+// Provider 支付渠道
+type Provider interface {
+	// Name 渠道名称，保存在支付记录中
+	Name() string
+	// CreatePrepay 创建预支付交易
+	CreatePrepay(ctx context.Context, req PrepayRequest) (*PrepayResult, error)
+	// Query 按商户订单号查询交易
+	Query(ctx context.Context, orderNo string) (*Transaction, error)
+	// Refund 申请退款
+	Refund(ctx context.Context, req RefundRequest) (*RefundResult, error)
+	// QueryRefund 按商户退款单号查询退款，用于同步处理中的退款
+	QueryRefund(ctx context.Context, refundNo string) (*RefundResult, error)
+	// VerifyNotify 校验支付回调签名并解析交易信息
+	VerifyNotify(r *http.Request) (*Transaction, error)
+}
@@ -0,0 +1,409 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// WeChatPayConfig 微信支付 v3 配置
+type WeChatPayConfig struct {
+	AppID                 string // 小程序 appid
+	MchID                 string // 商户号
+	SerialNo              string // 商户 API 证书序列号
+	PrivateKeyPath        string // 商户 API 私钥 apiclient_key.pem
+	APIv3Key              string // APIv3 密钥，用于解密回调
+	PlatformPublicKeyPath string // 微信支付公钥，用于验签
+	PlatformSerial        string // 微信支付公钥 ID
+	NotifyURL             string // 支付结果回调地址
+	BaseURL               string // 默认 https://api.mch.weixin.qq.com
+}
+
+// WeChatPay 微信支付 v3 JSAPI/小程序支付
+type WeChatPay struct {
+	cfg        WeChatPayConfig
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+	client     *http.Client
+}
+
+// 回调时间戳允许的最大偏差
+const notifyMaxSkew = 5 * time.Minute
+
+// NewWeChatPay 加载密钥并创建微信支付渠道
+func NewWeChatPay(cfg WeChatPayConfig) (*WeChatPay, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.mch.weixin.qq.com"
+	}
+	if len(cfg.APIv3Key) != 32 {
+		return nil, errors.New("wechat pay apiv3 key must be 32 bytes")
+	}
+
+	privateKey, err := loadPrivateKey(cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("load merchant private key: %w", err)
+	}
+	publicKey, err := loadPublicKey(cfg.PlatformPublicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("load wechat pay public key: %w", err)
+	}
+
+	return &WeChatPay{
+		cfg:        cfg,
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Name 渠道名称
+func (p *WeChatPay) Name() string { return "wechat" }
+
+// CreatePrepay 调用 JSAPI 下单并生成小程序调起支付的参数
+func (p *WeChatPay) CreatePrepay(ctx context.Context, req PrepayRequest) (*PrepayResult, error) {
+	body := map[string]interface{}{
+		"appid":        p.cfg.AppID,
+		"mchid":        p.cfg.MchID,
+		"description":  req.Description,
+		"out_trade_no": req.OrderNo,
+		"notify_url":   p.cfg.NotifyURL,
+		"amount":       map[string]interface{}{"total": req.Amount, "currency": "CNY"},
+		"payer":        map[string]string{"openid": req.OpenID},
+	}
+	if !req.ExpiresAt.IsZero() {
+		body["time_expire"] = req.ExpiresAt.Format(time.RFC3339)
+	}
+
+	var resp struct {
+		PrepayID string `json:"prepay_id"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/v3/pay/transactions/jsapi", body, &resp); err != nil {
+		return nil, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := randomNonce()
+	pkg := "prepay_id=" + resp.PrepayID
+	paySign, err := p.sign(p.cfg.AppID + "\n" + timestamp + "\n" + nonce + "\n" + pkg + "\n")
+	if err != nil {
+		return nil, err
+	}
+
+	return &PrepayResult{
+		PrepayID: resp.PrepayID,
+		Params: map[string]string{
+			"appId":     p.cfg.AppID,
+			"timeStamp": timestamp,
+			"nonceStr":  nonce,
+			"package":   pkg,
+			"signType":  "RSA",
+			"paySign":   paySign,
+		},
+	}, nil
+}
+
+// wechatTransaction 微信支付交易结构，查询接口和回调解密后的内容相同
+type wechatTransaction struct {
+	OutTradeNo    string `json:"out_trade_no"`
+	TransactionID string `json:"transaction_id"`
+	TradeState    string `json:"trade_state"`
+	SuccessTime   string `json:"success_time"`
+	Amount        struct {
+		Total int64 `json:"total"`
+	} `json:"amount"`
+}
+
+func (t wechatTransaction) toTransaction() *Transaction {
+	tx := &Transaction{
+		OrderNo:       t.OutTradeNo,
+		TransactionID: t.TransactionID,
+		State:         t.TradeState,
+		Amount:        t.Amount.Total,
+	}
+	if t.SuccessTime != "" {
+		tx.PaidAt, _ = time.Parse(time.RFC3339, t.SuccessTime)
+	}
+	return tx
+}
+
+// Query 按商户订单号查询
+func (p *WeChatPay) Query(ctx context.Context, orderNo string) (*Transaction, error) {
+	path := "/v3/pay/transactions/out-trade-no/" + url.PathEscape(orderNo) + "?mchid=" + url.QueryEscape(p.cfg.MchID)
+	var resp wechatTransaction
+	if err := p.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.toTransaction(), nil
+}
+
+// wechatRefund 退款申请和退款查询的应答
+type wechatRefund struct {
+	RefundID string `json:"refund_id"`
+	Status   string `json:"status"`
+}
+
+// Refund 申请退款
+func (p *WeChatPay) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	body := map[string]interface{}{
+		"out_trade_no":  req.OrderNo,
+		"out_refund_no": req.RefundNo,
+		"reason":        req.Reason,
+		"amount": map[string]interface{}{
+			"refund":   req.Amount,
+			"total":    req.Total,
+			"currency": "CNY",
+		},
+	}
+	var resp wechatRefund
+	if err := p.do(ctx, http.MethodPost, "/v3/refund/domestic/refunds", body, &resp); err != nil {
+		return nil, err
+	}
+	return &RefundResult{RefundID: resp.RefundID, Status: resp.Status}, nil
+}
+
+// QueryRefund 按商户退款单号查询
+func (p *WeChatPay) QueryRefund(ctx context.Context, refundNo string) (*RefundResult, error) {
+	var resp wechatRefund
+	if err := p.do(ctx, http.MethodGet, "/v3/refund/domestic/refunds/"+url.PathEscape(refundNo), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &RefundResult{RefundID: resp.RefundID, Status: resp.Status}, nil
+}
+
+// VerifyNotify 校验回调签名，解密 resource 得到交易信息
+func (p *WeChatPay) VerifyNotify(r *http.Request) (*Transaction, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if err := p.verify(r.Header, body); err != nil {
+		return nil, err
+	}
+
+	var notify struct {
+		EventType string `json:"event_type"`
+		Resource  struct {
+			Algorithm      string `json:"algorithm"`
+			Ciphertext     string `json:"ciphertext"`
+			AssociatedData string `json:"associated_data"`
+			Nonce          string `json:"nonce"`
+		} `json:"resource"`
+	}
+	if err := json.Unmarshal(body, &notify); err != nil {
+		return nil, fmt.Errorf("decode notify: %w", err)
+	}
+	if notify.Resource.Algorithm != "AEAD_AES_256_GCM" {
+		return nil, fmt.Errorf("unsupported notify algorithm %q", notify.Resource.Algorithm)
+	}
+
+	plaintext, err := p.decrypt(notify.Resource.Ciphertext, notify.Resource.Nonce, notify.Resource.AssociatedData)
+	if err != nil {
+		return nil, err
+	}
+	var t wechatTransaction
+	if err := json.Unmarshal(plaintext, &t); err != nil {
+		return nil, fmt.Errorf("decode notify resource: %w", err)
+	}
+	return t.toTransaction(), nil
+}
+
+// do 发送签名请求并校验响应签名
+func (p *WeChatPay) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.cfg.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	authorization, err := p.authorization(method, path, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if p.cfg.PlatformSerial != "" {
+		req.Header.Set("Wechatpay-Serial", p.cfg.PlatformSerial)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(respBody, &apiErr)
+		return fmt.Errorf("wechat pay %s %s: %d %s %s", method, path, resp.StatusCode, apiErr.Code, apiErr.Message)
+	}
+	if err := p.verify(resp.Header, respBody); err != nil {
+		return err
+	}
+	if out != nil && len(respBody) > 0 {
+		return json.Unmarshal(respBody, out)
+	}
+	return nil
+}
+
+// authorization 生成 WECHATPAY2-SHA256-RSA2048 认证头
+func (p *WeChatPay) authorization(method, path string, body []byte) (string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := randomNonce()
+	message := method + "\n" + path + "\n" + timestamp + "\n" + nonce + "\n" + string(body) + "\n"
+	signature, err := p.sign(message)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`WECHATPAY2-SHA256-RSA2048 mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		p.cfg.MchID, nonce, signature, timestamp, p.cfg.SerialNo), nil
+}
+
+// sign 使用商户私钥 SHA256withRSA 签名
+func (p *WeChatPay) sign(message string) (string, error) {
+	hashed := sha256.Sum256([]byte(message))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// verify 使用微信支付公钥校验应答或回调签名
+func (p *WeChatPay) verify(header http.Header, body []byte) error {
+	timestamp := header.Get("Wechatpay-Timestamp")
+	nonce := header.Get("Wechatpay-Nonce")
+	signature := header.Get("Wechatpay-Signature")
+	if timestamp == "" || nonce == "" || signature == "" {
+		return ErrInvalidSignature
+	}
+	if serial := header.Get("Wechatpay-Serial"); p.cfg.PlatformSerial != "" && serial != p.cfg.PlatformSerial {
+		return ErrInvalidSignature
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > notifyMaxSkew || skew < -notifyMaxSkew {
+		return ErrInvalidSignature
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	hashed := sha256.Sum256([]byte(timestamp + "\n" + nonce + "\n" + string(body) + "\n"))
+	if rsa.VerifyPKCS1v15(p.publicKey, crypto.SHA256, hashed[:], sig) != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// decrypt 使用 APIv3 密钥 AES-256-GCM 解密回调资源
+func (p *WeChatPay) decrypt(ciphertext, nonce, associatedData string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher([]byte(p.cfg.APIv3Key))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(nonce))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, []byte(nonce), data, []byte(associatedData))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt notify resource: %w", err)
+	}
+	return plaintext, nil
+}
+
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+			return rsaKey, nil
+		}
+		return nil, errors.New("private key is not rsa")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func loadPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+	var key interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = cert.PublicKey
+	default:
+		if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not rsa")
+	}
+	return rsaKey, nil
+}
+
+// randomNonce 32 位随机字符串
+func randomNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
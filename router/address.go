@@ -0,0 +1,254 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------------------------------------收货地址
+
+// maxAddresses 每个买家最多保存的地址数
+const maxAddresses = 20
+
+var (
+	errRegionInvalid   = errors.New("省市区选择有误")
+	errAddressNotFound = errors.New("收货地址不存在")
+)
+
+// phonePattern 手机号或带区号的固定电话
+var phonePattern = regexp.MustCompile(`^(1\d{10}|0\d{2,3}-?\d{7,8})$`)
+
+// regionIndex 省市区数据及按编码的索引
+type regionIndex struct {
+	tree   []models.Region
+	names  map[string]string
+	parent map[string]string
+	leaf   map[string]bool
+}
+
+// loadRegions 加载地区数据并建立索引
+func loadRegions(filePath string) (*regionIndex, error) {
+	idx := &regionIndex{
+		names:  make(map[string]string),
+		parent: make(map[string]string),
+		leaf:   make(map[string]bool),
+	}
+	if err := utils.LoadJSONData(filePath, &idx.tree); err != nil {
+		return nil, err
+	}
+	var walk func(nodes []models.Region, parent string, depth int)
+	walk = func(nodes []models.Region, parent string, depth int) {
+		for _, n := range nodes {
+			idx.names[n.Code] = n.Name
+			idx.parent[n.Code] = parent
+			if depth == 3 {
+				idx.leaf[n.Code] = true
+			}
+			walk(n.Children, n.Code, depth+1)
+		}
+	}
+	walk(idx.tree, "", 1)
+	return idx, nil
+}
+
+// resolve 校验省市区编码的层级关系，返回对应名称
+func (idx *regionIndex) resolve(req addressRequest) (string, string, string, error) {
+	province, city, district := req.ProvinceCode, req.CityCode, req.DistrictCode
+	if !idx.leaf[district] || idx.parent[district] != city || idx.parent[city] != province || idx.parent[province] != "" {
+		return "", "", "", errRegionInvalid
+	}
+	if _, ok := idx.names[province]; !ok {
+		return "", "", "", errRegionInvalid
+	}
+	return idx.names[province], idx.names[city], idx.names[district], nil
+}
+
+// 省市区数据，供小程序地址选择器使用
+func regionsHandler(regions *regionIndex) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jsonResponse(c, http.StatusOK, "获取成功", regions.tree)
+	}
+}
+
+// addressRequest 新增、修改地址的参数
+type addressRequest struct {
+	RecipientName string `json:"recipient_name" binding:"required,max=50"`
+	Phone         string `json:"phone" binding:"required"`
+	ProvinceCode  string `json:"province_code" binding:"required"`
+	CityCode      string `json:"city_code" binding:"required"`
+	DistrictCode  string `json:"district_code" binding:"required"`
+	Detail        string `json:"detail" binding:"required,max=200"`
+	IsDefault     bool   `json:"is_default"`
+}
+
+// bindAddress 解析地址参数并填充到 addr，省市区名称以地区数据为准
+func bindAddress(c *gin.Context, regions *regionIndex, addr *models.Address) bool {
+	var req addressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+		return false
+	}
+	if !phonePattern.MatchString(req.Phone) {
+		jsonResponse(c, http.StatusBadRequest, "联系电话格式错误", nil)
+		return false
+	}
+	province, city, district, err := regions.resolve(req)
+	if err != nil {
+		jsonResponse(c, http.StatusBadRequest, err.Error(), nil)
+		return false
+	}
+
+	addr.RecipientName = req.RecipientName
+	addr.Phone = req.Phone
+	addr.ProvinceCode, addr.CityCode, addr.DistrictCode = req.ProvinceCode, req.CityCode, req.DistrictCode
+	addr.Province, addr.City, addr.District = province, city, district
+	addr.Detail = req.Detail
+	addr.IsDefault = req.IsDefault
+	return true
+}
+
+// saveAddress 在事务内保存地址，设为默认时取消其他默认地址
+func saveAddress(db *gorm.DB, addr *models.Address) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if addr.IsDefault {
+			if err := tx.Model(&models.Address{}).
+				Where("user_id = ? AND id <> ?", addr.UserID, addr.ID).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(addr).Error
+	})
+}
+
+// findUserAddress 查询买家自己的地址
+func findUserAddress(db *gorm.DB, userID uint, id interface{}) (models.Address, error) {
+	var addr models.Address
+	err := db.Where("id = ? AND user_id = ?", id, userID).First(&addr).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return addr, errAddressNotFound
+	}
+	return addr, err
+}
+
+// 买家地址列表，默认地址排在最前
+func addressListHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var list []models.Address
+		if err := db.Where("user_id = ?", c.MustGet("userID").(uint)).Order("is_default DESC, updated_at DESC").Find(&list).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取地址失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", list)
+	}
+}
+
+// 买家新增地址，第一个地址自动设为默认
+func addressCreateHandler(db *gorm.DB, regions *regionIndex) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uint)
+		addr := models.Address{UserID: userID}
+		if !bindAddress(c, regions, &addr) {
+			return
+		}
+
+		var count int64
+		if err := db.Model(&models.Address{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "新增地址失败", nil)
+			return
+		}
+		if count >= maxAddresses {
+			jsonResponse(c, http.StatusBadRequest, "收货地址数量已达上限", nil)
+			return
+		}
+		if count == 0 {
+			addr.IsDefault = true
+		}
+
+		if err := saveAddress(db, &addr); err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "新增地址失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusCreated, "新增成功", addr)
+	}
+}
+
+// 买家修改地址
+func addressUpdateHandler(db *gorm.DB, regions *regionIndex) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		addr, err := findUserAddress(db, c.MustGet("userID").(uint), c.Param("id"))
+		if err != nil {
+			jsonResponse(c, http.StatusNotFound, errAddressNotFound.Error(), nil)
+			return
+		}
+		wasDefault := addr.IsDefault
+		if !bindAddress(c, regions, &addr) {
+			return
+		}
+		// 默认地址只能通过设置其他地址为默认来取消
+		addr.IsDefault = addr.IsDefault || wasDefault
+
+		if err := saveAddress(db, &addr); err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "修改地址失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "修改成功", addr)
+	}
+}
+
+// 买家设置默认地址
+func addressSetDefaultHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		addr, err := findUserAddress(db, c.MustGet("userID").(uint), c.Param("id"))
+		if err != nil {
+			jsonResponse(c, http.StatusNotFound, errAddressNotFound.Error(), nil)
+			return
+		}
+		addr.IsDefault = true
+		if err := saveAddress(db, &addr); err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "设置默认地址失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "设置成功", addr)
+	}
+}
+
+// 买家删除地址，删除默认地址时把最近修改的地址设为默认
+func addressDeleteHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uint)
+		addr, err := findUserAddress(db, userID, c.Param("id"))
+		if err != nil {
+			jsonResponse(c, http.StatusNotFound, errAddressNotFound.Error(), nil)
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&addr).Error; err != nil {
+				return err
+			}
+			if !addr.IsDefault {
+				return nil
+			}
+			var next models.Address
+			err := tx.Where("user_id = ?", userID).Order("updated_at DESC").First(&next).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			return tx.Model(&next).Update("is_default", true).Error
+		})
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "删除地址失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "删除成功", nil)
+	}
+}
@@ -0,0 +1,45 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAddressRegions(t *testing.T) {
+	s := newTestServer(t)
+	token := s.user("buyer")
+	address := func(province, city, district string) testResponse {
+		return s.call(http.MethodPost, "/api/public/v1/my/addresses", token, gin.H{
+			"recipient_name": "张三", "phone": "13800000000", "detail": "朝阳路1号",
+			"province_code": province, "city_code": city, "district_code": district,
+			"province": "台湾省", "city": "台北市", "district": "中正区",
+		})
+	}
+
+	tests := []struct {
+		province, city, district string
+		full                     string
+	}{
+		{"11", "1101", "110105", "北京市朝阳区朝阳路1号"},
+		{"65", "6540", "654004", "新疆维吾尔自治区伊犁哈萨克自治州霍尔果斯市朝阳路1号"},
+		{"42", "4290", "429004", "湖北省仙桃市朝阳路1号"},
+		{"44", "4419", "441900", "广东省东莞市朝阳路1号"},
+	}
+	for _, tt := range tests {
+		resp := address(tt.province, tt.city, tt.district)
+		expect(t, resp, http.StatusCreated)
+		var addr models.Address
+		resp.decode(t, &addr)
+		if got := addr.FullAddress(); got != tt.full {
+			t.Errorf("%s: full address = %q, want %q", tt.district, got, tt.full)
+		}
+	}
+
+	// 地区数据以外的编码和错位的上下级一律拒绝，不使用请求中填写的名称
+	expect(t, address("71", "7101", "710101"), http.StatusBadRequest)
+	expect(t, address("11", "1101", "310101"), http.StatusBadRequest)
+	expect(t, address("44", "4419", "441901"), http.StatusBadRequest)
+}
@@ -0,0 +1,360 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------------------------------------管理端：管理员和角色
+
+// adminView 管理员对外展示的信息，不包含密码
+type adminView struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+func toAdminView(a models.Admin) adminView {
+	return adminView{ID: a.ID, Username: a.Username, Role: a.Role}
+}
+
+// permissionCodes 把权限集合转换为有序列表
+func permissionCodes(set map[string]bool) []string {
+	codes := make([]string, 0, len(set))
+	for code := range set {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// roleExists 校验角色名是否存在
+func roleExists(db *gorm.DB, name string) bool {
+	if name == models.RoleSuperAdmin {
+		return true
+	}
+	var count int64
+	db.Model(&models.Role{}).Where("name = ?", name).Count(&count)
+	return count > 0
+}
+
+// findPermissions 按权限编码查询权限，存在未知编码时返回错误
+func findPermissions(db *gorm.DB, codes []string) ([]models.Permission, error) {
+	var perms []models.Permission
+	if len(codes) == 0 {
+		return perms, nil
+	}
+	if err := db.Where("code IN ?", codes).Find(&perms).Error; err != nil {
+		return nil, err
+	}
+	if len(perms) != len(uniqueStrings(codes)) {
+		return nil, errors.New("存在未知的权限编码")
+	}
+	return perms, nil
+}
+
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	result := make([]string, 0, len(list))
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// 当前管理员信息
+func adminProfileHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var admin models.Admin
+		if err := db.First(&admin, c.MustGet("adminID").(uint)).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "管理员不存在", nil)
+			return
+		}
+		permissions := c.MustGet("adminPermissions").(map[string]bool)
+		jsonResponse(c, http.StatusOK, "获取成功", gin.H{
+			"admin":       toAdminView(admin),
+			"permissions": permissionCodes(permissions),
+		})
+	}
+}
+
+// 管理员列表
+func adminListAdminsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var admins []models.Admin
+		if err := db.Order("id").Find(&admins).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取管理员列表失败", nil)
+			return
+		}
+		list := make([]adminView, 0, len(admins))
+		for _, a := range admins {
+			list = append(list, toAdminView(a))
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", list)
+	}
+}
+
+// 创建管理员
+func adminCreateAdminHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Username string `json:"username" binding:"required,max=50"`
+			Password string `json:"password" binding:"required,min=6,max=72"`
+			Role     string `json:"role" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+		if !roleExists(db, req.Role) {
+			jsonResponse(c, http.StatusBadRequest, "角色不存在", nil)
+			return
+		}
+
+		var count int64
+		db.Model(&models.Admin{}).Where("username = ?", req.Username).Count(&count)
+		if count > 0 {
+			jsonResponse(c, http.StatusBadRequest, "用户名已存在", nil)
+			return
+		}
+
+		hashed, err := utils.HashPassword(req.Password)
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "创建管理员失败", nil)
+			return
+		}
+		admin := models.Admin{Username: req.Username, Password: hashed, Role: req.Role}
+		if err := db.Create(&admin).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "创建管理员失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusCreated, "创建成功", toAdminView(admin))
+	}
+}
+
+// 修改管理员角色或重置密码
+func adminUpdateAdminHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Role     string `json:"role"`
+			Password string `json:"password" binding:"omitempty,min=6,max=72"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		var admin models.Admin
+		if err := db.First(&admin, c.Param("id")).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "管理员不存在", nil)
+			return
+		}
+
+		updates := map[string]interface{}{}
+		if req.Role != "" && req.Role != admin.Role {
+			if !roleExists(db, req.Role) {
+				jsonResponse(c, http.StatusBadRequest, "角色不存在", nil)
+				return
+			}
+			// 防止最后一个超级管理员被降级导致无人可管理
+			if admin.Role == models.RoleSuperAdmin && countSuperAdmins(db) <= 1 {
+				jsonResponse(c, http.StatusBadRequest, "至少保留一个超级管理员", nil)
+				return
+			}
+			updates["role"] = req.Role
+		}
+		if req.Password != "" {
+			hashed, err := utils.HashPassword(req.Password)
+			if err != nil {
+				jsonResponse(c, http.StatusInternalServerError, "更新管理员失败", nil)
+				return
+			}
+			updates["password"] = hashed
+			updates["salt"] = ""
+		}
+		if len(updates) > 0 {
+			if err := db.Model(&admin).Updates(updates).Error; err != nil {
+				jsonResponse(c, http.StatusInternalServerError, "更新管理员失败", nil)
+				return
+			}
+		}
+		jsonResponse(c, http.StatusOK, "更新成功", toAdminView(admin))
+	}
+}
+
+// 删除管理员
+func adminDeleteAdminHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var admin models.Admin
+		if err := db.First(&admin, c.Param("id")).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "管理员不存在", nil)
+			return
+		}
+		if admin.ID == c.MustGet("adminID").(uint) {
+			jsonResponse(c, http.StatusBadRequest, "不能删除当前登录的管理员", nil)
+			return
+		}
+		if admin.Role == models.RoleSuperAdmin && countSuperAdmins(db) <= 1 {
+			jsonResponse(c, http.StatusBadRequest, "至少保留一个超级管理员", nil)
+			return
+		}
+		if err := db.Unscoped().Delete(&admin).Error; err != nil { // 物理删除，释放用户名
+			jsonResponse(c, http.StatusInternalServerError, "删除管理员失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "删除成功", nil)
+	}
+}
+
+func countSuperAdmins(db *gorm.DB) int64 {
+	var count int64
+	db.Model(&models.Admin{}).Where("role = ?", models.RoleSuperAdmin).Count(&count)
+	return count
+}
+
+// 权限列表
+func adminListPermissionsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var perms []models.Permission
+		if err := db.Order("id").Find(&perms).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取权限列表失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", perms)
+	}
+}
+
+// 角色列表
+func adminListRolesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var roles []models.Role
+		if err := db.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取角色列表失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", roles)
+	}
+}
+
+// 创建角色
+func adminCreateRoleHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name        string   `json:"name" binding:"required,max=20"`
+			Description string   `json:"description" binding:"max=100"`
+			Permissions []string `json:"permissions"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+		if roleExists(db, req.Name) {
+			jsonResponse(c, http.StatusBadRequest, "角色已存在", nil)
+			return
+		}
+		perms, err := findPermissions(db, req.Permissions)
+		if err != nil {
+			jsonResponse(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+
+		role := models.Role{Name: req.Name, Description: req.Description, Permissions: perms}
+		if err := db.Create(&role).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "创建角色失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusCreated, "创建成功", role)
+	}
+}
+
+// 修改角色描述和权限
+func adminUpdateRoleHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Description *string  `json:"description" binding:"omitempty,max=100"`
+			Permissions []string `json:"permissions"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		var role models.Role
+		if err := db.First(&role, c.Param("id")).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "角色不存在", nil)
+			return
+		}
+		if role.Name == models.RoleSuperAdmin && req.Permissions != nil {
+			jsonResponse(c, http.StatusBadRequest, "超级管理员权限不可修改", nil)
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if req.Description != nil {
+				if err := tx.Model(&role).Update("description", *req.Description).Error; err != nil {
+					return err
+				}
+			}
+			if req.Permissions != nil {
+				perms, err := findPermissions(tx, req.Permissions)
+				if err != nil {
+					return err
+				}
+				if err := tx.Model(&role).Association("Permissions").Replace(perms); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			jsonResponse(c, http.StatusBadRequest, "更新角色失败: "+err.Error(), nil)
+			return
+		}
+
+		db.Preload("Permissions").First(&role, role.ID)
+		jsonResponse(c, http.StatusOK, "更新成功", role)
+	}
+}
+
+// 删除角色，仍有管理员使用的角色不能删除
+func adminDeleteRoleHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var role models.Role
+		if err := db.First(&role, c.Param("id")).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "角色不存在", nil)
+			return
+		}
+		if role.Name == models.RoleSuperAdmin {
+			jsonResponse(c, http.StatusBadRequest, "超级管理员角色不可删除", nil)
+			return
+		}
+
+		var count int64
+		db.Model(&models.Admin{}).Where("role = ?", role.Name).Count(&count)
+		if count > 0 {
+			jsonResponse(c, http.StatusBadRequest, "该角色仍有管理员在使用", nil)
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(&role).Error // 物理删除，释放角色名
+		})
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "删除角色失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "删除成功", nil)
+	}
+}
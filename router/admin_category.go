@@ -0,0 +1,342 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------------------------------------管理端：分类管理
+
+var (
+	errCategoryNotFound = errors.New("分类不存在")
+	errCategoryParent   = errors.New("上级分类不存在")
+	errCategoryCycle    = errors.New("不能移动到自身或其下级分类下")
+	errCategoryTooDeep  = errors.New("分类最多三级")
+	errCategoryChildren = errors.New("排序列表必须包含该分类下的全部子分类")
+)
+
+// categoryErrorStatus 分类业务错误对应的 HTTP 状态码
+func categoryErrorStatus(err error) int {
+	switch err {
+	case errCategoryNotFound:
+		return http.StatusNotFound
+	case errCategoryParent, errCategoryCycle, errCategoryTooDeep, errCategoryChildren:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondCategoryError 返回分类相关错误，未知错误不暴露细节
+func respondCategoryError(c *gin.Context, err error, fallback string) {
+	status := categoryErrorStatus(err)
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		msg = fallback
+	}
+	jsonResponse(c, status, msg, nil)
+}
+
+// loadCategoryTree 从数据库读取分类并组装成树，includeDeleted 为 false 时跳过已删除分类
+func loadCategoryTree(db *gorm.DB, includeDeleted bool) ([]models.CategoryTree, error) {
+	query := db.Order("cat_level, cat_sort, cat_id")
+	if !includeDeleted {
+		query = query.Where("cat_deleted = ?", false)
+	}
+	var list []models.CategoryTree
+	if err := query.Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]models.CategoryTree)
+	for _, cat := range list {
+		children[cat.CatPid] = append(children[cat.CatPid], cat)
+	}
+	var build func(pid int) []models.CategoryTree
+	build = func(pid int) []models.CategoryTree {
+		nodes := children[pid]
+		for i := range nodes {
+			nodes[i].Children = build(nodes[i].CatID)
+		}
+		return nodes
+	}
+	tree := build(0)
+	if tree == nil {
+		tree = []models.CategoryTree{}
+	}
+	return tree, nil
+}
+
+// findCategory 查询未删除的分类
+func findCategory(db *gorm.DB, id interface{}) (models.CategoryTree, error) {
+	var cat models.CategoryTree
+	err := db.Where("cat_id = ? AND cat_deleted = ?", id, false).First(&cat).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return cat, errCategoryNotFound
+	}
+	return cat, err
+}
+
+// categoryParentLevel 返回上级分类的层级，顶级分类的上级为 -1
+func categoryParentLevel(db *gorm.DB, pid int) (int, error) {
+	if pid == 0 {
+		return -1, nil
+	}
+	parent, err := findCategory(db, pid)
+	if err == errCategoryNotFound {
+		return 0, errCategoryParent
+	}
+	return parent.CatLevel, err
+}
+
+// descendantCategories 返回分类的全部下级分类ID，按层级从上到下
+func descendantCategories(db *gorm.DB, id int) ([]int, error) {
+	var result []int
+	parents := []int{id}
+	for len(parents) > 0 {
+		var ids []int
+		if err := db.Model(&models.CategoryTree{}).Where("cat_pid IN ?", parents).Pluck("cat_id", &ids).Error; err != nil {
+			return nil, err
+		}
+		result = append(result, ids...)
+		parents = ids
+	}
+	return result, nil
+}
+
+// nextCategorySort 新分类排在同级最后
+func nextCategorySort(db *gorm.DB, pid int) (int, error) {
+	var maxSort int
+	err := db.Model(&models.CategoryTree{}).Where("cat_pid = ?", pid).
+		Select("COALESCE(MAX(cat_sort), -1)").Scan(&maxSort).Error
+	return maxSort + 1, err
+}
+
+// 分类树，include_deleted=1 时包含已删除分类
+func adminListCategoriesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tree, err := loadCategoryTree(db, c.Query("include_deleted") == "1")
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取分类失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", tree)
+	}
+}
+
+// 新增分类
+func adminCreateCategoryHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			CatName string `json:"cat_name" binding:"required,max=50"`
+			CatPid  int    `json:"cat_pid" binding:"min=0"`
+			CatIcon string `json:"cat_icon" binding:"max=255"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		var cat models.CategoryTree
+		err := db.Transaction(func(tx *gorm.DB) error {
+			parentLevel, err := categoryParentLevel(tx, req.CatPid)
+			if err != nil {
+				return err
+			}
+			if parentLevel+1 > models.MaxCategoryLevel {
+				return errCategoryTooDeep
+			}
+			sort, err := nextCategorySort(tx, req.CatPid)
+			if err != nil {
+				return err
+			}
+			cat = models.CategoryTree{
+				CatName:  req.CatName,
+				CatPid:   req.CatPid,
+				CatLevel: parentLevel + 1,
+				CatIcon:  req.CatIcon,
+				CatSort:  sort,
+			}
+			return tx.Create(&cat).Error
+		})
+		if err != nil {
+			respondCategoryError(c, err, "新增分类失败")
+			return
+		}
+		jsonResponse(c, http.StatusCreated, "新增成功", cat)
+	}
+}
+
+// 修改分类名称和图标
+func adminUpdateCategoryHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			CatName string  `json:"cat_name" binding:"required,max=50"`
+			CatIcon *string `json:"cat_icon" binding:"omitempty,max=255"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+		cat, err := findCategory(db, c.Param("id"))
+		if err != nil {
+			respondCategoryError(c, err, "修改分类失败")
+			return
+		}
+
+		cat.CatName = req.CatName
+		if req.CatIcon != nil {
+			cat.CatIcon = *req.CatIcon
+		}
+		if err := db.Save(&cat).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "修改分类失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "修改成功", cat)
+	}
+}
+
+// 移动分类到新的上级分类下，下级分类一起移动
+func adminMoveCategoryHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			CatPid int `json:"cat_pid" binding:"min=0"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		var cat models.CategoryTree
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if cat, err = findCategory(tx, c.Param("id")); err != nil {
+				return err
+			}
+			descendants, err := descendantCategories(tx, cat.CatID)
+			if err != nil {
+				return err
+			}
+			if req.CatPid == cat.CatID {
+				return errCategoryCycle
+			}
+			for _, id := range descendants {
+				if id == req.CatPid {
+					return errCategoryCycle
+				}
+			}
+
+			parentLevel, err := categoryParentLevel(tx, req.CatPid)
+			if err != nil {
+				return err
+			}
+			// 整棵子树的最深层级不能超过限制
+			deepest := cat.CatLevel
+			if len(descendants) > 0 {
+				if err := tx.Model(&models.CategoryTree{}).Where("cat_id IN ?", descendants).
+					Select("MAX(cat_level)").Scan(&deepest).Error; err != nil {
+					return err
+				}
+			}
+			shift := parentLevel + 1 - cat.CatLevel
+			if deepest+shift > models.MaxCategoryLevel {
+				return errCategoryTooDeep
+			}
+
+			sort, err := nextCategorySort(tx, req.CatPid)
+			if err != nil {
+				return err
+			}
+			if shift != 0 && len(descendants) > 0 {
+				if err := tx.Model(&models.CategoryTree{}).Where("cat_id IN ?", descendants).
+					UpdateColumn("cat_level", gorm.Expr("cat_level + ?", shift)).Error; err != nil {
+					return err
+				}
+			}
+			cat.CatPid = req.CatPid
+			cat.CatLevel += shift
+			cat.CatSort = sort
+			return tx.Save(&cat).Error
+		})
+		if err != nil {
+			respondCategoryError(c, err, "移动分类失败")
+			return
+		}
+		jsonResponse(c, http.StatusOK, "移动成功", cat)
+	}
+}
+
+// 调整同一上级分类下子分类的顺序
+func adminReorderCategoriesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			CatPid int   `json:"cat_pid" binding:"min=0"`
+			IDs    []int `json:"ids" binding:"required,min=1"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var current []int
+			if err := tx.Model(&models.CategoryTree{}).
+				Where("cat_pid = ? AND cat_deleted = ?", req.CatPid, false).
+				Pluck("cat_id", &current).Error; err != nil {
+				return err
+			}
+			if len(current) != len(req.IDs) {
+				return errCategoryChildren
+			}
+			seen := make(map[int]bool, len(current))
+			for _, id := range current {
+				seen[id] = true
+			}
+			for _, id := range req.IDs {
+				if !seen[id] {
+					return errCategoryChildren
+				}
+				delete(seen, id)
+			}
+
+			for i, id := range req.IDs {
+				if err := tx.Model(&models.CategoryTree{}).Where("cat_id = ?", id).UpdateColumn("cat_sort", i).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			respondCategoryError(c, err, "调整排序失败")
+			return
+		}
+		jsonResponse(c, http.StatusOK, "排序已更新", nil)
+	}
+}
+
+// 删除分类，下级分类一起标记为已删除
+func adminDeleteCategoryHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			cat, err := findCategory(tx, c.Param("id"))
+			if err != nil {
+				return err
+			}
+			descendants, err := descendantCategories(tx, cat.CatID)
+			if err != nil {
+				return err
+			}
+			ids := append(descendants, cat.CatID)
+			return tx.Model(&models.CategoryTree{}).Where("cat_id IN ?", ids).UpdateColumn("cat_deleted", true).Error
+		})
+		if err != nil {
+			respondCategoryError(c, err, "删除分类失败")
+			return
+		}
+		jsonResponse(c, http.StatusOK, "删除成功", nil)
+	}
+}
@@ -0,0 +1,468 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------------------------------------管理端：首页内容
+
+var (
+	errContentNotFound  = errors.New("内容不存在")
+	errContentWindow    = errors.New("结束时间必须晚于开始时间")
+	errContentOpenType  = errors.New("不支持的跳转方式")
+	errContentLink      = errors.New("跳转链接格式错误")
+	errContentGoods     = errors.New("链接的商品不存在")
+	errContentCategory  = errors.New("链接的分类不存在")
+	errContentNoProduct = errors.New("楼层至少需要一个商品入口")
+)
+
+// contentOpenTypes 小程序 navigator 支持的跳转方式
+var contentOpenTypes = map[string]bool{
+	"":          true,
+	"navigate":  true,
+	"redirect":  true,
+	"switchTab": true,
+	"reLaunch":  true,
+}
+
+// contentErrorStatus 首页内容业务错误对应的 HTTP 状态码
+func contentErrorStatus(err error) int {
+	switch err {
+	case errContentNotFound:
+		return http.StatusNotFound
+	case errContentWindow, errContentOpenType, errContentLink, errContentGoods, errContentCategory, errContentNoProduct:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondContentError 返回首页内容相关错误，未知错误不暴露细节
+func respondContentError(c *gin.Context, err error, fallback string) {
+	status := contentErrorStatus(err)
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		msg = fallback
+	}
+	jsonResponse(c, status, msg, nil)
+}
+
+// activeContent 只查询已启用且在展示时间内的内容
+func activeContent(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("enabled = ?", true).
+			Where("start_at IS NULL OR start_at <= ?", now).
+			Where("end_at IS NULL OR end_at > ?", now)
+	}
+}
+
+// 首页公开接口返回的内容，不包含排序、启用状态和展示时间等管理字段
+
+type bannerView struct {
+	ImageSrc     string `json:"image_src"`
+	OpenType     string `json:"open_type"`
+	GoodsID      int    `json:"goods_id"`
+	NavigatorURL string `json:"navigator_url"`
+}
+
+type navItemView struct {
+	Name         string `json:"name"`
+	ImageSrc     string `json:"image_src"`
+	OpenType     string `json:"open_type,omitempty"`
+	NavigatorURL string `json:"navigator_url,omitempty"`
+}
+
+type floorProductView struct {
+	Name         string `json:"name"`
+	ImageSrc     string `json:"image_src"`
+	ImageWidth   string `json:"image_width"`
+	OpenType     string `json:"open_type"`
+	NavigatorURL string `json:"navigator_url"`
+}
+
+type floorView struct {
+	FloorTitle  models.FloorTitle  `json:"floor_title"`
+	ProductList []floorProductView `json:"product_list"`
+}
+
+func toBannerViews(banners []models.Banner) []bannerView {
+	views := make([]bannerView, 0, len(banners))
+	for _, b := range banners {
+		views = append(views, bannerView{ImageSrc: b.ImageSrc, OpenType: b.OpenType, GoodsID: b.GoodsID, NavigatorURL: b.NavigatorURL})
+	}
+	return views
+}
+
+func toNavItemViews(items []models.Category) []navItemView {
+	views := make([]navItemView, 0, len(items))
+	for _, n := range items {
+		views = append(views, navItemView{Name: n.Name, ImageSrc: n.ImageSrc, OpenType: n.OpenType, NavigatorURL: n.NavigatorURL})
+	}
+	return views
+}
+
+func toFloorViews(floors []models.Floor) []floorView {
+	views := make([]floorView, 0, len(floors))
+	for _, f := range floors {
+		products := make([]floorProductView, 0, len(f.ProductList))
+		for _, p := range f.ProductList {
+			products = append(products, floorProductView{
+				Name:         p.Name,
+				ImageSrc:     p.ImageSrc,
+				ImageWidth:   p.ImageWidth,
+				OpenType:     p.OpenType,
+				NavigatorURL: p.NavigatorURL,
+			})
+		}
+		views = append(views, floorView{FloorTitle: f.FloorTitle, ProductList: products})
+	}
+	return views
+}
+
+// contentScheduleRequest 排序、启用和展示时间参数
+type contentScheduleRequest struct {
+	Sort    int        `json:"sort"`
+	Enabled *bool      `json:"enabled"` // 新增时默认启用
+	StartAt *time.Time `json:"start_at"`
+	EndAt   *time.Time `json:"end_at"`
+}
+
+// apply 校验并写入展示设置
+func (r contentScheduleRequest) apply(s *models.ContentSchedule, creating bool) error {
+	if r.StartAt != nil && r.EndAt != nil && !r.EndAt.After(*r.StartAt) {
+		return errContentWindow
+	}
+	s.Sort = r.Sort
+	s.StartAt = r.StartAt
+	s.EndAt = r.EndAt
+	if r.Enabled != nil {
+		s.Enabled = *r.Enabled
+	} else if creating {
+		s.Enabled = true
+	}
+	return nil
+}
+
+// validateContentLink 校验跳转链接，指向商品或分类时要求对应数据存在
+// 支持 /pages/goods_detail/main?goods_id=1、/pages/goods_list?cid=1 等小程序页面路径
+func validateContentLink(db *gorm.DB, openType, link string) error {
+	if !contentOpenTypes[openType] {
+		return errContentOpenType
+	}
+	if link == "" {
+		return nil
+	}
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "" || u.Host != "" || !strings.HasPrefix(u.Path, "/pages/") {
+		return errContentLink
+	}
+
+	query := u.Query()
+	if v := query.Get("goods_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return errContentLink
+		}
+		if err := checkContentGoods(db, uint(id)); err != nil {
+			return err
+		}
+	}
+	if v := query.Get("cid"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return errContentLink
+		}
+		var count int64
+		if err := db.Model(&models.CategoryTree{}).Where("cat_id = ? AND cat_deleted = ?", id, false).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errContentCategory
+		}
+	}
+	return nil
+}
+
+// checkContentGoods 校验商品存在
+func checkContentGoods(db *gorm.DB, goodsID uint) error {
+	var count int64
+	if err := db.Model(&models.Goods{}).Where("goods_id = ?", goodsID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errContentGoods
+	}
+	return nil
+}
+
+// findContent 按路由中的 id 查询内容，新增时 id 为空直接返回
+func findContent(db *gorm.DB, c *gin.Context, dest interface{}) (bool, error) {
+	id := c.Param("id")
+	if id == "" {
+		return true, nil
+	}
+	err := db.First(dest, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, errContentNotFound
+	}
+	return false, err
+}
+
+// contentSavedStatus 新增返回 201，修改返回 200
+func contentSavedStatus(creating bool) int {
+	if creating {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
+// 轮播图列表，包含未启用和不在展示时间内的
+func adminListBannersHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var list []models.Banner
+		if err := db.Order("sort, id").Find(&list).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取轮播图失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", list)
+	}
+}
+
+// 新增或修改轮播图
+// 只填写 goods_id 时自动生成商品详情页链接
+func adminSaveBannerHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			ImageSrc     string `json:"image_src" binding:"required,max=255"`
+			OpenType     string `json:"open_type"`
+			GoodsID      int    `json:"goods_id" binding:"min=0"`
+			NavigatorURL string `json:"navigator_url" binding:"max=255"`
+			contentScheduleRequest
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		var banner models.Banner
+		creating, err := findContent(db, c, &banner)
+		if err == nil {
+			err = req.apply(&banner.ContentSchedule, creating)
+		}
+		if err == nil && req.GoodsID != 0 {
+			if req.NavigatorURL == "" {
+				req.NavigatorURL = "/pages/goods_detail/main?goods_id=" + strconv.Itoa(req.GoodsID)
+				if req.OpenType == "" {
+					req.OpenType = "navigate"
+				}
+			}
+			err = checkContentGoods(db, uint(req.GoodsID))
+		}
+		if err == nil {
+			err = validateContentLink(db, req.OpenType, req.NavigatorURL)
+		}
+		if err != nil {
+			respondContentError(c, err, "保存轮播图失败")
+			return
+		}
+
+		banner.ImageSrc = req.ImageSrc
+		banner.OpenType = req.OpenType
+		banner.GoodsID = req.GoodsID
+		banner.NavigatorURL = req.NavigatorURL
+		if err := db.Save(&banner).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "保存轮播图失败", nil)
+			return
+		}
+		jsonResponse(c, contentSavedStatus(creating), "保存成功", banner)
+	}
+}
+
+// 导航入口列表
+func adminListNavItemsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var list []models.Category
+		if err := db.Order("sort, id").Find(&list).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取导航入口失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", list)
+	}
+}
+
+// 新增或修改导航入口
+func adminSaveNavItemHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name         string `json:"name" binding:"required,max=50"`
+			ImageSrc     string `json:"image_src" binding:"required,max=255"`
+			OpenType     string `json:"open_type"`
+			NavigatorURL string `json:"navigator_url" binding:"max=255"`
+			contentScheduleRequest
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		var item models.Category
+		creating, err := findContent(db, c, &item)
+		if err == nil {
+			err = req.apply(&item.ContentSchedule, creating)
+		}
+		if err == nil {
+			err = validateContentLink(db, req.OpenType, req.NavigatorURL)
+		}
+		if err != nil {
+			respondContentError(c, err, "保存导航入口失败")
+			return
+		}
+
+		item.Name = req.Name
+		item.ImageSrc = req.ImageSrc
+		item.OpenType = req.OpenType
+		item.NavigatorURL = req.NavigatorURL
+		if err := db.Save(&item).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "保存导航入口失败", nil)
+			return
+		}
+		jsonResponse(c, contentSavedStatus(creating), "保存成功", item)
+	}
+}
+
+// 楼层列表
+func adminListFloorsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var list []models.Floor
+		err := db.Preload("ProductList", func(tx *gorm.DB) *gorm.DB {
+			return tx.Order("sort, id")
+		}).Order("sort, id").Find(&list).Error
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取楼层失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", list)
+	}
+}
+
+// 新增或修改楼层，商品入口按提交的顺序整体替换
+func adminSaveFloorHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			FloorTitle struct {
+				Name     string `json:"name" binding:"required,max=50"`
+				ImageSrc string `json:"image_src" binding:"max=255"`
+			} `json:"floor_title"`
+			ProductList []struct {
+				Name         string `json:"name" binding:"max=50"`
+				ImageSrc     string `json:"image_src" binding:"required,max=255"`
+				ImageWidth   string `json:"image_width" binding:"max=10"`
+				OpenType     string `json:"open_type"`
+				NavigatorURL string `json:"navigator_url" binding:"max=255"`
+			} `json:"product_list" binding:"dive"`
+			contentScheduleRequest
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		var floor models.Floor
+		var creating bool
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if creating, err = findContent(tx, c, &floor); err != nil {
+				return err
+			}
+			if err := req.apply(&floor.ContentSchedule, creating); err != nil {
+				return err
+			}
+			if len(req.ProductList) == 0 {
+				return errContentNoProduct
+			}
+
+			products := make([]models.FloorProduct, 0, len(req.ProductList))
+			for i, p := range req.ProductList {
+				if err := validateContentLink(tx, p.OpenType, p.NavigatorURL); err != nil {
+					return err
+				}
+				products = append(products, models.FloorProduct{
+					Name:         p.Name,
+					ImageSrc:     p.ImageSrc,
+					ImageWidth:   p.ImageWidth,
+					OpenType:     p.OpenType,
+					NavigatorURL: p.NavigatorURL,
+					Sort:         i,
+				})
+			}
+
+			floor.FloorTitle = models.FloorTitle{Name: req.FloorTitle.Name, ImageSrc: req.FloorTitle.ImageSrc}
+			floor.ProductList = nil
+			if err := tx.Save(&floor).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("floor_id = ?", floor.ID).Delete(&models.FloorProduct{}).Error; err != nil {
+				return err
+			}
+			for i := range products {
+				products[i].FloorID = floor.ID
+			}
+			if err := tx.Create(&products).Error; err != nil {
+				return err
+			}
+			floor.ProductList = products
+			return nil
+		})
+		if err != nil {
+			respondContentError(c, err, "保存楼层失败")
+			return
+		}
+		jsonResponse(c, contentSavedStatus(creating), "保存成功", floor)
+	}
+}
+
+// 删除楼层及其商品入口
+func adminDeleteFloorHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var floor models.Floor
+			if _, err := findContent(tx, c, &floor); err != nil {
+				return err
+			}
+			if err := tx.Where("floor_id = ?", floor.ID).Delete(&models.FloorProduct{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&floor).Error
+		})
+		if err != nil {
+			respondContentError(c, err, "删除楼层失败")
+			return
+		}
+		jsonResponse(c, http.StatusOK, "删除成功", nil)
+	}
+}
+
+// adminDeleteContentHandler 删除轮播图、导航入口等单表内容，newModel 返回对应模型的空值
+func adminDeleteContentHandler(db *gorm.DB, newModel func() interface{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Where("id = ?", c.Param("id")).Delete(newModel())
+		if result.Error != nil {
+			jsonResponse(c, http.StatusInternalServerError, "删除失败", nil)
+			return
+		}
+		if result.RowsAffected == 0 {
+			jsonResponse(c, http.StatusNotFound, errContentNotFound.Error(), nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "删除成功", nil)
+	}
+}
@@ -0,0 +1,103 @@
+package router
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------------------------------------管理端：鲜花内容审核
+
+// 全部商家的鲜花列表，支持按商家、状态和关键字筛选
+func adminListFlowersHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+		if page < 1 {
+			page = 1
+		}
+		if pageSize < 1 || pageSize > 100 {
+			pageSize = 10
+		}
+
+		query := db.Model(&models.Flower{})
+		if merchantID := c.Query("merchant_id"); merchantID != "" {
+			query = query.Where("merchant_id = ?", merchantID)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if keyword := c.Query("keyword"); keyword != "" {
+			query = query.Where("name LIKE ?", "%"+keyword+"%")
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取鲜花总数失败", nil)
+			return
+		}
+		var flowers []models.Flower
+		if err := query.Preload("Images", orderedImages).Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&flowers).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取鲜花列表失败", nil)
+			return
+		}
+		for i := range flowers {
+			resolveFlowerImages(store, &flowers[i])
+		}
+
+		jsonResponse(c, http.StatusOK, "获取成功", gin.H{
+			"list":      flowers,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		})
+	}
+}
+
+// 下架违规鲜花，同时从公共商品目录移除；商家修改后可以重新上架
+func adminTakeDownFlowerHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Reason string `json:"reason" binding:"required,max=255"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "请填写原因", nil)
+			return
+		}
+
+		var flower models.Flower
+		if err := db.First(&flower, c.Param("id")).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "鲜花不存在", nil)
+			return
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// 带上原状态条件，避免并发操作重复下架
+			result := tx.Model(&models.Flower{}).Where("id = ? AND status = 1", flower.ID).Update("status", 0)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return syncFlowerCatalog(tx, store, flower.ID)
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			jsonResponse(c, http.StatusConflict, "鲜花已下架", nil)
+			return
+		}
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "操作失败", nil)
+			return
+		}
+		log.Printf("admin %d took down flower %d: %s", c.MustGet("adminID").(uint), flower.ID, req.Reason)
+
+		flower.Status = 0
+		jsonResponse(c, http.StatusOK, "已下架", flower)
+	}
+}
@@ -0,0 +1,192 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------------------------------------管理端：商家管理
+
+// merchantView 管理端展示的商家信息，不包含密码
+type merchantView struct {
+	ID           uint      `json:"id"`
+	Username     string    `json:"username"`
+	ShopName     string    `json:"shop_name"`
+	Email        string    `json:"email"`
+	Phone        string    `json:"phone"`
+	Address      string    `json:"address"`
+	Status       int       `json:"status"`
+	StatusReason string    `json:"status_reason"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func toMerchantView(m models.Merchant) merchantView {
+	return merchantView{
+		ID:           m.ID,
+		Username:     m.Username,
+		ShopName:     m.ShopName,
+		Email:        m.Email,
+		Phone:        m.Phone,
+		Address:      m.Address,
+		Status:       m.Status,
+		StatusReason: m.StatusReason,
+		CreatedAt:    m.CreatedAt,
+	}
+}
+
+// merchantTransitions 审核操作 -> 允许的原状态、目标状态以及是否必须填写原因
+var merchantTransitions = map[string]struct {
+	From         int
+	To           int
+	NeedReason   bool
+	RevokeTokens bool
+}{
+	"approve":    {models.MerchantStatusPending, models.MerchantStatusNormal, false, false},
+	"reject":     {models.MerchantStatusPending, models.MerchantStatusRejected, true, false},
+	"suspend":    {models.MerchantStatusNormal, models.MerchantStatusDisabled, true, true},
+	"reactivate": {models.MerchantStatusDisabled, models.MerchantStatusNormal, false, false},
+}
+
+// 商家列表，支持按状态和关键字筛选
+func adminListMerchantsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+		if page < 1 {
+			page = 1
+		}
+		if pageSize < 1 || pageSize > 100 {
+			pageSize = 10
+		}
+
+		query := db.Model(&models.Merchant{})
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if keyword := c.Query("keyword"); keyword != "" {
+			like := "%" + keyword + "%"
+			query = query.Where("username LIKE ? OR shop_name LIKE ? OR phone LIKE ?", like, like, like)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取商家总数失败", nil)
+			return
+		}
+
+		var merchants []models.Merchant
+		if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&merchants).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取商家列表失败", nil)
+			return
+		}
+
+		list := make([]merchantView, 0, len(merchants))
+		for _, m := range merchants {
+			list = append(list, toMerchantView(m))
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", gin.H{
+			"list":      list,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		})
+	}
+}
+
+// 商家详情及状态变更记录
+func adminGetMerchantHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var merchant models.Merchant
+		if err := db.First(&merchant, c.Param("id")).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "商家不存在", nil)
+			return
+		}
+
+		var logs []models.MerchantStatusLog
+		db.Where("merchant_id = ?", merchant.ID).Order("id DESC").Find(&logs)
+
+		jsonResponse(c, http.StatusOK, "获取成功", gin.H{
+			"merchant": toMerchantView(merchant),
+			"logs":     logs,
+		})
+	}
+}
+
+// 审核通过、驳回、禁用、恢复商家
+func adminChangeMerchantStatusHandler(db *gorm.DB, store storage.Storage, action string) gin.HandlerFunc {
+	transition := merchantTransitions[action]
+	return func(c *gin.Context) {
+		var req struct {
+			Reason string `json:"reason" binding:"max=255"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+		if transition.NeedReason && req.Reason == "" {
+			jsonResponse(c, http.StatusBadRequest, "请填写原因", nil)
+			return
+		}
+
+		var merchant models.Merchant
+		if err := db.First(&merchant, c.Param("id")).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "商家不存在", nil)
+			return
+		}
+		if merchant.Status != transition.From {
+			jsonResponse(c, http.StatusConflict, "当前商家状态不允许该操作", nil)
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			updates := map[string]interface{}{
+				"status":        transition.To,
+				"status_reason": req.Reason,
+			}
+			if transition.RevokeTokens {
+				updates["token_version"] = gorm.Expr("token_version + 1")
+			}
+			// 带上原状态条件，避免并发操作重复变更
+			result := tx.Model(&models.Merchant{}).
+				Where("id = ? AND status = ?", merchant.ID, transition.From).
+				Updates(updates)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			if err := tx.Create(&models.MerchantStatusLog{
+				MerchantID: merchant.ID,
+				AdminID:    c.MustGet("adminID").(uint),
+				Action:     action,
+				FromStatus: transition.From,
+				ToStatus:   transition.To,
+				Reason:     req.Reason,
+			}).Error; err != nil {
+				return err
+			}
+			// 停用的商家鲜花从公共商品目录移除，恢复后重新上架，与状态变更一起提交
+			return syncMerchantCatalog(tx, store, merchant.ID)
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			jsonResponse(c, http.StatusConflict, "当前商家状态不允许该操作", nil)
+			return
+		}
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "操作失败", nil)
+			return
+		}
+
+		merchant.Status = transition.To
+		merchant.StatusReason = req.Reason
+		jsonResponse(c, http.StatusOK, "操作成功", toMerchantView(merchant))
+	}
+}
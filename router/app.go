@@ -3,37 +3,63 @@ package router
 import (
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"path"
 	"strconv"
 	"time"
 
+	"github.com/LookAt-MeNow/flowers/identity"
 	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/payment"
+	"github.com/LookAt-MeNow/flowers/sql"
+	"github.com/LookAt-MeNow/flowers/storage"
 	"github.com/LookAt-MeNow/flowers/utils"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // SetupRouter 初始化 Gin 路由并返回引擎实例
-func SetupRouter(db *gorm.DB) *gin.Engine {
+// pay 由调用方创建，与退款同步任务共用同一个支付渠道
+func SetupRouter(db *gorm.DB, cfg *sql.Config, store storage.Storage, pay payment.Provider) *gin.Engine {
 	r := gin.Default()
+
+	// 小程序登录身份提供方
+	idp, err := identity.New(cfg.WeChat.Provider, cfg.WeChat.AppID, cfg.WeChat.AppSecret)
+	if err != nil {
+		log.Fatalf("init identity provider: %v", err)
+	}
+	// 省市区数据，用于收货地址校验
+	regions, err := loadRegions("data/regions.json")
+	if err != nil {
+		log.Fatalf("load regions: %v", err)
+	}
+
+	// 图片上传限制
+	uploads := newUploadLimits(cfg)
+
 	// 配置公共中间件
 	r.Use(CORSMiddleware())
 	r.Use(ResponseWrapper())
 
+	// 上传的图片
+	r.GET(mediaRoute+"/*key", mediaHandler(store))
+	r.HEAD(mediaRoute+"/*key", mediaHandler(store))
+
 	// 注册路由组
 	api := r.Group("/api/public/v1")
 	{
 		// 首页相关路由
 		home := api.Group("/home")
 		{
-			home.GET("/swiperdata", swiperHandler)
-			home.GET("/catitems", catItemsHandler)
-			home.GET("/floordata", floorHandler)
+			home.GET("/swiperdata", swiperHandler(db))
+			home.GET("/catitems", catItemsHandler(db))
+			home.GET("/floordata", floorHandler(db))
 		}
 
 		// 分类相关路由
-		api.GET("/categories", categoriesHandler)
+		api.GET("/categories", categoriesHandler(db))
+		api.GET("/regions", regionsHandler(regions)) // 省市区数据
 
 		// 商品相关路由
 		goods := api.Group("/goods")
@@ -48,7 +74,7 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 			})
 			// 商品详情
 			goods.GET("/detail", func(c *gin.Context) {
-				goodsDetailHandler(c, db) // 将 db 传递给 goodsDetailHandler
+				goodsDetailHandler(c, db, store) // 将 db 传递给 goodsDetailHandler
 			})
 
 		}
@@ -59,22 +85,169 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 				merchantRegisterHandler(c, db)
 			})
 			auth.POST("/merchants/login", func(c *gin.Context) {
-				merchantLoginHandler(c, db)
+				merchantLoginHandler(c, db, cfg)
 			})
 			auth.POST("/admin/login", func(c *gin.Context) {
-				adminLoginHandler(c, db)
+				adminLoginHandler(c, db, cfg)
 			})
+			auth.POST("/users/login", userLoginHandler(db, cfg, idp)) // 小程序买家登录
+		}
+
+		// 买家个人中心，需要登录
+		my := api.Group("/my")
+		my.Use(UserAuthMiddleware(cfg, db))
+		{
+			my.GET("/profile", userProfileHandler(db))
+			my.PUT("/profile", userUpdateProfileHandler(db))
+
+			// 收货地址
+			my.GET("/addresses", addressListHandler(db))
+			my.POST("/addresses", addressCreateHandler(db, regions))
+			my.PUT("/addresses/:id", addressUpdateHandler(db, regions))
+			my.PUT("/addresses/:id/default", addressSetDefaultHandler(db))
+			my.DELETE("/addresses/:id", addressDeleteHandler(db))
+
+			// 购物车
+			my.GET("/cart", cartListHandler(db, store))
+			my.POST("/cart", cartAddHandler(db))
+			my.PUT("/cart/select", cartSelectHandler(db))
+			my.PUT("/cart/:id", cartUpdateHandler(db))
+			my.DELETE("/cart/:id", cartDeleteHandler(db))
+
+			// 订单
+			my.POST("/orders", orderCheckoutHandler(db, cfg, store))
+			my.GET("/orders", userListOrdersHandler(db))
+			my.GET("/orders/:id", userGetOrderHandler(db))
+			my.POST("/orders/:id/cancel", userOrderActionHandler(db, models.OrderStatusCancelled, "买家取消订单"))
+			my.POST("/orders/:id/confirm", userOrderActionHandler(db, models.OrderStatusCompleted, "买家确认收货"))
+			my.POST("/orders/:id/pay", orderPayHandler(db, pay))
+			my.POST("/orders/:id/pay/sync", orderPaySyncHandler(db, pay))
 		}
 
+		// 下单页查询商家可预约的配送时段
+		api.GET("/delivery/slots", deliverySlotsHandler(db))
+
+		// 支付结果回调，由支付渠道调用，签名校验在处理函数内完成
+		api.POST("/pay/notify", paymentNotifyHandler(db, pay))
+
 		// 在 SetupRouter 鲜花上架修改
 		merchant := api.Group("/merchants")
+		merchant.Use(MerchantAuthMiddleware(cfg, db))
 		{
 			// 鲜花管理
-			merchant.GET("/flowers", merchantListFlowersHandler(db))       // 获取鲜花列表
-			merchant.POST("/flowers", merchantAddFlowerHandler(db))        // 添加鲜花
-			merchant.GET("/flowers/:id", merchantGetFlowerHandler(db))     // 获取单个鲜花
-			merchant.PUT("/flowers/:id", merchantUpdateFlowerHandler(db))  // 更新鲜花
-			merchant.PUT("/flowers/:id/status", merchantUpdateFlowerStatusHandler(db)) // 更新状态
+			merchant.GET("/flowers", merchantListFlowersHandler(db, store))       // 获取鲜花列表
+			merchant.POST("/flowers", merchantAddFlowerHandler(db, store, uploads))        // 添加鲜花
+			merchant.GET("/flowers/:id", merchantGetFlowerHandler(db, store))     // 获取单个鲜花
+			merchant.PUT("/flowers/:id", merchantUpdateFlowerHandler(db, store, uploads))  // 更新鲜花
+			merchant.PATCH("/flowers/:id", merchantPatchFlowerHandler(db, store))          // 部分更新鲜花(JSON)
+			merchant.PUT("/flowers/:id/status", merchantUpdateFlowerStatusHandler(db, store)) // 更新状态
+
+			// 鲜花图片
+			merchant.POST("/flowers/:id/images", merchantAddFlowerImagesHandler(db, store, uploads))
+			merchant.PUT("/flowers/:id/images/order", merchantReorderFlowerImagesHandler(db, store))
+			merchant.PUT("/flowers/:id/images/:image_id/cover", merchantSetFlowerCoverHandler(db, store))
+			merchant.DELETE("/flowers/:id/images/:image_id", merchantDeleteFlowerImageHandler(db, store))
+
+			// 鲜花规格(SKU)
+			merchant.GET("/flowers/:id/skus", merchantGetFlowerSKUsHandler(db, store))
+			merchant.PUT("/flowers/:id/skus", merchantSaveFlowerSKUsHandler(db, store))
+			merchant.DELETE("/flowers/:id/skus", merchantDeleteFlowerSKUsHandler(db, store))
+			merchant.PATCH("/flowers/:id/skus/:sku_id", merchantPatchFlowerSKUHandler(db, store))
+
+			// 订单管理
+			merchant.GET("/orders", merchantListOrdersHandler(db))
+			merchant.GET("/orders/:id", merchantGetOrderHandler(db))
+			merchant.PUT("/orders/:id/status", merchantUpdateOrderStatusHandler(db))
+
+			// 配送排期
+			merchant.GET("/delivery/settings", merchantGetDeliverySettingHandler(db))
+			merchant.PUT("/delivery/settings", merchantUpdateDeliverySettingHandler(db))
+			merchant.GET("/delivery/slots", merchantListDeliverySlotsHandler(db))
+			merchant.POST("/delivery/slots", merchantCreateDeliverySlotHandler(db))
+			merchant.PUT("/delivery/slots/:id", merchantUpdateDeliverySlotHandler(db))
+			merchant.DELETE("/delivery/slots/:id", merchantDeleteDeliverySlotHandler(db))
+			merchant.GET("/delivery/blackouts", merchantListBlackoutsHandler(db))
+			merchant.POST("/delivery/blackouts", merchantCreateBlackoutHandler(db))
+			merchant.DELETE("/delivery/blackouts/:id", merchantDeleteBlackoutHandler(db))
+			merchant.GET("/delivery/schedule", merchantDeliveryScheduleHandler(db)) // 某天的配送单
+		}
+
+		// 管理端路由，按权限分组
+		admin := api.Group("/admin")
+		admin.Use(AdminAuthMiddleware(cfg, db))
+		{
+			admin.GET("/profile", adminProfileHandler(db)) // 当前管理员信息
+
+			// 管理员和角色管理
+			manage := admin.Group("", RequirePermission(models.PermAdminManage))
+			{
+				manage.GET("/admins", adminListAdminsHandler(db))
+				manage.POST("/admins", adminCreateAdminHandler(db))
+				manage.PUT("/admins/:id", adminUpdateAdminHandler(db))
+				manage.DELETE("/admins/:id", adminDeleteAdminHandler(db))
+				manage.GET("/permissions", adminListPermissionsHandler(db))
+				manage.GET("/roles", adminListRolesHandler(db))
+				manage.POST("/roles", adminCreateRoleHandler(db))
+				manage.PUT("/roles/:id", adminUpdateRoleHandler(db))
+				manage.DELETE("/roles/:id", adminDeleteRoleHandler(db))
+			}
+
+			// 商家管理
+			admin.GET("/merchants", RequirePermission(models.PermMerchantView), adminListMerchantsHandler(db))
+			admin.GET("/merchants/:id", RequirePermission(models.PermMerchantView), adminGetMerchantHandler(db))
+			review := admin.Group("/merchants/:id", RequirePermission(models.PermMerchantDisable))
+			{
+				review.POST("/approve", adminChangeMerchantStatusHandler(db, store, "approve"))
+				review.POST("/reject", adminChangeMerchantStatusHandler(db, store, "reject"))
+				review.POST("/suspend", adminChangeMerchantStatusHandler(db, store, "suspend"))
+				review.POST("/reactivate", adminChangeMerchantStatusHandler(db, store, "reactivate"))
+			}
+
+			// 鲜花内容审核
+			moderate := admin.Group("/flowers", RequirePermission(models.PermFlowerModerate))
+			{
+				moderate.GET("", adminListFlowersHandler(db, store))
+				moderate.POST("/:id/takedown", adminTakeDownFlowerHandler(db, store))
+			}
+
+			// 订单退款
+			admin.POST("/orders/:id/refund", RequirePermission(models.PermOrderRefund), adminRefundOrderHandler(db, pay))
+
+			// 平台商品订单发货
+			fulfill := admin.Group("/platform-orders", RequirePermission(models.PermOrderFulfill))
+			{
+				fulfill.GET("", adminListPlatformOrdersHandler(db))
+				fulfill.GET("/:id", adminGetPlatformOrderHandler(db))
+				fulfill.PUT("/:id/status", adminUpdatePlatformOrderStatusHandler(db))
+			}
+
+			// 分类管理
+			category := admin.Group("/categories", RequirePermission(models.PermContentEdit))
+			{
+				category.GET("", adminListCategoriesHandler(db))
+				category.POST("", adminCreateCategoryHandler(db))
+				category.PUT("/reorder", adminReorderCategoriesHandler(db))
+				category.PUT("/:id", adminUpdateCategoryHandler(db))
+				category.PUT("/:id/move", adminMoveCategoryHandler(db))
+				category.DELETE("/:id", adminDeleteCategoryHandler(db))
+			}
+
+			// 首页内容管理
+			home := admin.Group("/home", RequirePermission(models.PermContentEdit))
+			{
+				home.GET("/banners", adminListBannersHandler(db))
+				home.POST("/banners", adminSaveBannerHandler(db))
+				home.PUT("/banners/:id", adminSaveBannerHandler(db))
+				home.DELETE("/banners/:id", adminDeleteContentHandler(db, func() interface{} { return &models.Banner{} }))
+				home.GET("/nav-items", adminListNavItemsHandler(db))
+				home.POST("/nav-items", adminSaveNavItemHandler(db))
+				home.PUT("/nav-items/:id", adminSaveNavItemHandler(db))
+				home.DELETE("/nav-items/:id", adminDeleteContentHandler(db, func() interface{} { return &models.Category{} }))
+				home.GET("/floors", adminListFloorsHandler(db))
+				home.POST("/floors", adminSaveFloorHandler(db))
+				home.PUT("/floors/:id", adminSaveFloorHandler(db))
+				home.DELETE("/floors/:id", adminDeleteFloorHandler(db))
+			}
 		}
 	}
 	return r
@@ -86,7 +259,7 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 			if c.Request.Method == "OPTIONS" {
 				c.AbortWithStatus(204)
@@ -121,51 +294,61 @@ func ResponseWrapper() gin.HandlerFunc {
 }
 
 // 轮播图数据处理
-func swiperHandler(c *gin.Context) {
-	var banners []models.Banner
-	if err := utils.LoadJSONData("data/swiperdata.json", &banners); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": "轮播图数据加载失败",
-		})
-		return
+func swiperHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var banners []models.Banner
+		if err := db.Scopes(activeContent(time.Now())).Order("sort, id").Find(&banners).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "轮播图数据加载失败",
+			})
+			return
+		}
+		c.Set("response", toBannerViews(banners))
 	}
-	c.Set("response", banners)
 }
 
 // 分类页面数据处理
-func catItemsHandler(c *gin.Context) {
-	var catItems []models.Category
-	if err := utils.LoadJSONData("data/catitems.json", &catItems); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": "分类数据加载失败",
-		})
-		return
+func catItemsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var catItems []models.Category
+		if err := db.Scopes(activeContent(time.Now())).Order("sort, id").Find(&catItems).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "分类数据加载失败",
+			})
+			return
+		}
+		c.Set("response", toNavItemViews(catItems))
 	}
-	c.Set("response", catItems)
 }
 
 // 楼层数据处理
-func floorHandler(c *gin.Context) {
-	var floors []models.Floor
-	if err := utils.LoadJSONData("data/floordata.json", &floors); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": "楼层数据加载失败",
-		})
-		return
+func floorHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var floors []models.Floor
+		if err := db.Scopes(activeContent(time.Now())).Preload("ProductList", func(tx *gorm.DB) *gorm.DB {
+			return tx.Order("sort, id")
+		}).Order("sort, id").Find(&floors).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "楼层数据加载失败",
+			})
+			return
+		}
+		c.Set("response", toFloorViews(floors))
 	}
-	c.Set("response", floors)
 }
 
 // 分类数据处理
-func categoriesHandler(c *gin.Context) {
-	var categories []models.CategoryTree
-	if err := utils.LoadJSONData("data/categories.json", &categories); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": "分类树数据加载失败",
-		})
-		return
+func categoriesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		categories, err := loadCategoryTree(db, false)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "分类树数据加载失败",
+			})
+			return
+		}
+		c.Set("response", categories)
 	}
-	c.Set("response", categories)
 }
 
 // 搜索数据处理
@@ -279,7 +462,7 @@ func search_goods_list(c *gin.Context, db *gorm.DB) {
 }
 
 // 商品详情
-func goodsDetailHandler(c *gin.Context, db *gorm.DB) {
+func goodsDetailHandler(c *gin.Context, db *gorm.DB, store storage.Storage) {
 	goodsID := c.Query("goods_id")
 	if goodsID == "" {
 		c.JSON(http.StatusBadRequest, models.ApiResponse{
@@ -333,6 +516,14 @@ func goodsDetailHandler(c *gin.Context, db *gorm.DB) {
 	var attrs []models.GoodsAttr
 	db.Where("goods_id = ?", goodsID).Find(&attrs)
 
+	// 商家鲜花的规格，加入购物车时需选择 SKU
+	var flower models.Flower
+	if goods.FlowerID != 0 {
+		if err := db.Scopes(preloadVariants).Preload("Images", orderedImages).First(&flower, goods.FlowerID).Error; err == nil {
+			resolveFlowerImages(store, &flower)
+		}
+	}
+
 	// 构建响应数据结构
 	type ResponseDetail struct {
 		models.Goods
@@ -341,6 +532,8 @@ func goodsDetailHandler(c *gin.Context, db *gorm.DB) {
 		IsDel          string                `json:"is_del"`
 		Pics           []models.GoodsPicture `json:"pics"`
 		Attrs          []models.GoodsAttr    `json:"attrs"`
+		Options        []models.FlowerOption `json:"options,omitempty"`
+		SKUs           []models.FlowerSKU    `json:"skus,omitempty"`
 		AddTime        int64                 `json:"add_time"`
 		UpdTime        int64                 `json:"upd_time"`
 	}
@@ -352,6 +545,8 @@ func goodsDetailHandler(c *gin.Context, db *gorm.DB) {
 		IsDel:          goodsDetail.IsDel,
 		Pics:           pics,
 		Attrs:          attrs,
+		Options:        flower.Options,
+		SKUs:           flower.SKUs,
 		AddTime:        goods.AddTime.Unix(),
 		UpdTime:        goods.UpdTime.Unix(),
 	}
@@ -372,7 +567,7 @@ func goodsDetailHandler(c *gin.Context, db *gorm.DB) {
 func merchantRegisterHandler(c *gin.Context, db *gorm.DB) {
 	var req struct {
 		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required,min=6"`
+		Password string `json:"password" binding:"required,min=6,max=72"`
 		ShopName string `json:"shop_name" binding:"required"`
 		Email    string `json:"email" binding:"required,email"`
 		Phone    string `json:"phone" binding:"required"`
@@ -401,14 +596,26 @@ func merchantRegisterHandler(c *gin.Context, db *gorm.DB) {
 		return
 	}
 
-	// 创建商家（密码明文存储，实际生产环境不推荐）
+	// 密码哈希
+	hashed, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Meta: models.Meta{
+				Msg:    "注册失败",
+				Status: http.StatusInternalServerError,
+			},
+		})
+		return
+	}
+
+	// 创建商家
 	merchant := models.Merchant{
 		Username: req.Username,
-		Password: req.Password, // 明文存储密码
+		Password: hashed,
 		ShopName: req.ShopName,
 		Email:    req.Email,
 		Phone:    req.Phone,
-		Status:   1, // 默认激活状态
+		Status:   models.MerchantStatusPending, // 新注册商家需管理员审核
 	}
 
 	if err := db.Create(&merchant).Error; err != nil {
@@ -425,16 +632,17 @@ func merchantRegisterHandler(c *gin.Context, db *gorm.DB) {
 		Message: gin.H{
 			"username": merchant.Username,
 			"shopName": merchant.ShopName,
+			"status":   merchant.Status,
 		},
 		Meta: models.Meta{
-			Msg:    "注册成功",
+			Msg:    "注册成功，请等待审核",
 			Status: http.StatusCreated,
 		},
 	})
 }
 
 // 商家登录处理（简化版）
-func merchantLoginHandler(c *gin.Context, db *gorm.DB) {
+func merchantLoginHandler(c *gin.Context, db *gorm.DB, cfg *sql.Config) {
 	var req struct {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
@@ -450,9 +658,9 @@ func merchantLoginHandler(c *gin.Context, db *gorm.DB) {
 		return
 	}
 
-	// 查询商家
+	// 查询商家并校验密码
 	var merchant models.Merchant
-	if err := db.Where("username = ? AND password = ?", req.Username, req.Password).First(&merchant).Error; err != nil {
+	if err := db.Where("username = ?", req.Username).First(&merchant).Error; err != nil {
 		c.JSON(http.StatusUnauthorized, models.ApiResponse{
 			Meta: models.Meta{
 				Msg:    "用户名或密码错误",
@@ -461,21 +669,48 @@ func merchantLoginHandler(c *gin.Context, db *gorm.DB) {
 		})
 		return
 	}
+	ok, needRehash := utils.VerifyPassword(req.Password, merchant.Password, merchant.Salt)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ApiResponse{
+			Meta: models.Meta{
+				Msg:    "用户名或密码错误",
+				Status: http.StatusUnauthorized,
+			},
+		})
+		return
+	}
+	if needRehash {
+		upgradePassword(db, &merchant, req.Password)
+	}
 
 	// 检查商家状态
-	if merchant.Status != 1 {
+	if merchant.Status != models.MerchantStatusNormal {
 		c.JSON(http.StatusForbidden, models.ApiResponse{
 			Meta: models.Meta{
-				Msg:    "商家账号已被禁用",
+				Msg:    merchantStatusMessage(merchant),
 				Status: http.StatusForbidden,
 			},
 		})
 		return
 	}
 
-	// 登录成功，返回商家信息
+	// 签发访问令牌
+	token, err := utils.GenerateToken(cfg.Auth.JWTSecret, merchant.ID, utils.RoleMerchant, tokenTTL(cfg), merchant.TokenVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Meta: models.Meta{
+				Msg:    "登录失败",
+				Status: http.StatusInternalServerError,
+			},
+		})
+		return
+	}
+
+	// 登录成功，返回令牌和商家信息
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Message: gin.H{
+			"token":      token,
+			"expires_in": int(tokenTTL(cfg).Seconds()),
 			"merchant": gin.H{
 				"id":       merchant.ID,
 				"username": merchant.Username,
@@ -490,7 +725,7 @@ func merchantLoginHandler(c *gin.Context, db *gorm.DB) {
 }
 
 // 管理员登录处理（简化版）
-func adminLoginHandler(c *gin.Context, db *gorm.DB) {
+func adminLoginHandler(c *gin.Context, db *gorm.DB, cfg *sql.Config) {
 	var req struct {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
@@ -506,9 +741,9 @@ func adminLoginHandler(c *gin.Context, db *gorm.DB) {
 		return
 	}
 
-	// 查询管理员
+	// 查询管理员并校验密码
 	var admin models.Admin
-	if err := db.Where("username = ? AND password = ?", req.Username, req.Password).First(&admin).Error; err != nil {
+	if err := db.Where("username = ?", req.Username).First(&admin).Error; err != nil {
 		c.JSON(http.StatusUnauthorized, models.ApiResponse{
 			Meta: models.Meta{
 				Msg:    "用户名或密码错误",
@@ -517,13 +752,51 @@ func adminLoginHandler(c *gin.Context, db *gorm.DB) {
 		})
 		return
 	}
+	ok, needRehash := utils.VerifyPassword(req.Password, admin.Password, admin.Salt)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, models.ApiResponse{
+			Meta: models.Meta{
+				Msg:    "用户名或密码错误",
+				Status: http.StatusUnauthorized,
+			},
+		})
+		return
+	}
+	if needRehash {
+		upgradePassword(db, &admin, req.Password)
+	}
+
+	// 查询角色权限并签发访问令牌
+	permissions, err := loadRolePermissions(db, admin.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Meta: models.Meta{
+				Msg:    "登录失败",
+				Status: http.StatusInternalServerError,
+			},
+		})
+		return
+	}
+	token, err := utils.GenerateToken(cfg.Auth.JWTSecret, admin.ID, utils.RoleAdmin, tokenTTL(cfg), 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ApiResponse{
+			Meta: models.Meta{
+				Msg:    "登录失败",
+				Status: http.StatusInternalServerError,
+			},
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Message: gin.H{
+			"token":      token,
+			"expires_in": int(tokenTTL(cfg).Seconds()),
 			"admin": gin.H{
-				"id":       admin.ID,
-				"username": admin.Username,
-				"role":     admin.Role,
+				"id":          admin.ID,
+				"username":    admin.Username,
+				"role":        admin.Role,
+				"permissions": permissionCodes(permissions),
 			},
 		},
 		Meta: models.Meta{
@@ -534,9 +807,9 @@ func adminLoginHandler(c *gin.Context, db *gorm.DB) {
 }
 
 // 商家获取鲜花列表
-func merchantListFlowersHandler(db *gorm.DB) gin.HandlerFunc {
+func merchantListFlowersHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
     return func(c *gin.Context) {
-        merchantID := uint(1) // 临时使用固定值
+        merchantID := c.MustGet("merchantID").(uint)
         
         // 获取分页参数
         page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -568,13 +841,16 @@ func merchantListFlowersHandler(db *gorm.DB) gin.HandlerFunc {
         
         // 获取分页数据
         var flowers []models.Flower
-        if err := query.Offset(offset).Limit(pageSize).Preload("Images").Find(&flowers).Error; err != nil {
+        if err := query.Offset(offset).Limit(pageSize).Preload("Images", orderedImages).Find(&flowers).Error; err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{
                 "error": "获取鲜花列表失败",
                 "code": http.StatusInternalServerError,
             })
             return
         }
+        for i := range flowers {
+            resolveFlowerImages(store, &flowers[i])
+        }
         
         // 构建标准JSON响应
         response := gin.H{
@@ -595,11 +871,15 @@ func merchantListFlowersHandler(db *gorm.DB) gin.HandlerFunc {
 }
 
 // 商家添加鲜花
-func merchantAddFlowerHandler(db *gorm.DB) gin.HandlerFunc {
+func merchantAddFlowerHandler(db *gorm.DB, store storage.Storage, limits uploadLimits) gin.HandlerFunc {
     return func(c *gin.Context) {
-        //merchantID := c.MustGet("merchantID").(uint)
-		merchantID := uint(1) // 临时使用固定值 // 获取商家ID mustGet("merchantID") 是中间件设置的
-            // 打印接收到的表单数据
+        merchantID := c.MustGet("merchantID").(uint) // 商家ID由 MerchantAuthMiddleware 设置
+        // 处理图片上传，需在读取其他表单字段之前校验
+        images, err := parseImageUploads(c, limits)
+        if err != nil {
+            respondUploadError(c, err)
+            return
+        }
         // 验证必填字段
         if c.PostForm("name") == ""  {
             c.JSON(http.StatusBadRequest, models.ApiResponse{
@@ -611,20 +891,7 @@ func merchantAddFlowerHandler(db *gorm.DB) gin.HandlerFunc {
             return
         }
         
-        // 处理图片上传
-        form, err := c.MultipartForm()
-        if err != nil {
-            c.JSON(http.StatusBadRequest, models.ApiResponse{
-                Meta: models.Meta{
-                    Msg:    "请上传图片",
-                    Status: http.StatusBadRequest,
-                },
-            })
-            return
-        }
-        
-        files := form.File["images"]
-        if len(files) == 0 {
+        if len(images) == 0 {
             c.JSON(http.StatusBadRequest, models.ApiResponse{
                 Meta: models.Meta{
                     Msg:    "至少上传一张图片",
@@ -641,7 +908,13 @@ func merchantAddFlowerHandler(db *gorm.DB) gin.HandlerFunc {
         description := c.PostForm("description")
         status, _ := strconv.Atoi(c.PostForm("status"))
 
-        // 保存鲜花基本信息
+        // 先写入图片文件，再在一个事务中保存鲜花和图片记录，失败时删除已写入的文件
+        staged, err := stageImages(c, store, images, 0)
+        if err != nil {
+            log.Printf("save images for new flower failed: %v", err)
+            jsonResponse(c, http.StatusInternalServerError, "保存图片失败", nil)
+            return
+        }
         flower := models.Flower{
             MerchantID:  merchantID,
             Name:        name,
@@ -652,7 +925,21 @@ func merchantAddFlowerHandler(db *gorm.DB) gin.HandlerFunc {
             Status:      status,
         }
         
-        if err := db.Create(&flower).Error; err != nil {
+        err = db.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Omit(clause.Associations).Create(&flower).Error; err != nil {
+                return err
+            }
+            for i := range staged {
+                staged[i].FlowerID = flower.ID
+            }
+            if err := tx.Create(&staged).Error; err != nil {
+                return err
+            }
+            // 上架的鲜花同步到公共商品目录，同步失败时鲜花也不保存
+            return syncFlowerCatalog(tx, store, flower.ID)
+        })
+        if err != nil {
+            discardImages(store, staged)
             c.JSON(http.StatusInternalServerError, models.ApiResponse{
                 Meta: models.Meta{
                     Msg:    "创建鲜花失败",
@@ -661,37 +948,8 @@ func merchantAddFlowerHandler(db *gorm.DB) gin.HandlerFunc {
             })
             return
         }
-        
-        // 保存图片
-        var imagePaths []string
-        for _, file := range files {
-            // 生成唯一文件名
-            filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), file.Filename)
-            filepath := path.Join("uploads", filename)
-            
-            // 保存文件
-            if err := c.SaveUploadedFile(file, filepath); err != nil {
-                continue
-            }
-            
-            // 保存到数据库
-            image := models.FlowerImage{
-                FlowerID: flower.ID,
-                Path:     filepath,
-            }
-            if err := db.Create(&image).Error; err == nil {
-                imagePaths = append(imagePaths, filepath)
-            }
-        }
-        
-        // 更新鲜花图片信息
-        if len(imagePaths) > 0 {
-			flower.Images = []models.FlowerImage{}
-			for _, path := range imagePaths {
-				flower.Images = append(flower.Images, models.FlowerImage{Path: path})
-			}
-            db.Save(&flower)
-        }
+        flower.Images = staged
+        resolveFlowerImages(store, &flower)
         
         c.JSON(http.StatusCreated, models.ApiResponse{
             Message: flower,
@@ -704,14 +962,13 @@ func merchantAddFlowerHandler(db *gorm.DB) gin.HandlerFunc {
 }
 
 // 商家获取单个鲜花详情
-func merchantGetFlowerHandler(db *gorm.DB) gin.HandlerFunc {
+func merchantGetFlowerHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
     return func(c *gin.Context) {
-        //merchantID := c.MustGet("merchantID").(uint)
-		merchantID := uint(1) // 临时使用固定值
+        merchantID := c.MustGet("merchantID").(uint)
         flowerID := c.Param("id")
         
         var flower models.Flower
-        if err := db.Preload("Images").Where("id = ? AND merchant_id = ?", flowerID, merchantID).First(&flower).Error; err != nil {
+        if err := db.Scopes(preloadVariants).Preload("Images", orderedImages).Where("id = ? AND merchant_id = ?", flowerID, merchantID).First(&flower).Error; err != nil {
             c.JSON(http.StatusNotFound, models.ApiResponse{
                 Meta: models.Meta{
                     Msg:    "鲜花不存在或无权访问",
@@ -720,6 +977,7 @@ func merchantGetFlowerHandler(db *gorm.DB) gin.HandlerFunc {
             })
             return
         }
+        resolveFlowerImages(store, &flower)
         
         c.JSON(http.StatusOK, models.ApiResponse{
             Message: flower,
@@ -732,14 +990,21 @@ func merchantGetFlowerHandler(db *gorm.DB) gin.HandlerFunc {
 }
 
 // 商家更新鲜花信息
-func merchantUpdateFlowerHandler(db *gorm.DB) gin.HandlerFunc {
+func merchantUpdateFlowerHandler(db *gorm.DB, store storage.Storage, limits uploadLimits) gin.HandlerFunc {
     return func(c *gin.Context) {
-        //merchantID := c.MustGet("merchantID").(uint)
-		merchantID := uint(1) // 临时使用固定值
+        merchantID := c.MustGet("merchantID").(uint)
         flowerID := c.Param("id")
         
+        // 处理图片上传，需在读取其他表单字段之前校验；没有上传图片时不修改图片，
+        // 单张图片的增删和排序使用 /merchants/flowers/:id/images 接口
+        images, err := parseImageUploads(c, limits)
+        if err != nil && err != errUploadNotMultipart {
+            respondUploadError(c, err)
+            return
+        }
+        
         var flower models.Flower
-        if err := db.Preload("Images").Where("id = ? AND merchant_id = ?", flowerID, merchantID).First(&flower).Error; err != nil {
+        if err := db.Preload("Images", orderedImages).Where("id = ? AND merchant_id = ?", flowerID, merchantID).First(&flower).Error; err != nil {
             c.JSON(http.StatusNotFound, models.ApiResponse{
                 Meta: models.Meta{
                     Msg:    "鲜花不存在或无权访问",
@@ -752,67 +1017,77 @@ func merchantUpdateFlowerHandler(db *gorm.DB) gin.HandlerFunc {
         // 解析表单数据
         name := c.PostForm("name")
         price, _ := strconv.ParseFloat(c.PostForm("price"), 64)
-        stock, _ := strconv.Atoi(c.PostForm("stock"))
         categoryID, _ := strconv.Atoi(c.PostForm("category_id"))
         description := c.PostForm("description")
-        status, _ := strconv.Atoi(c.PostForm("status"))
         
-        // 更新字段
-        if name != "" {
-            flower.Name = name
+        // 有规格的鲜花价格和库存由 SKU 决定，忽略表单中的 price 和 stock
+        hasSKUs, err := flowerHasSKUs(db, flower.ID)
+        if err != nil {
+            jsonResponse(c, http.StatusInternalServerError, "更新鲜花失败", nil)
+            return
+        }
+        
+        // 只写入有变化的列，库存只在表单中填写时修改，避免覆盖下单时并发扣减的库存
+        updates := map[string]interface{}{}
+        if name != "" && name != flower.Name {
+            updates["name"] = name
         }
-        if price > 0 {
-            flower.Price = price
+        if price > 0 && price != flower.Price && !hasSKUs {
+            updates["price"] = price
         }
-        if stock >= 0 {
-            flower.Stock = stock
+        if raw, ok := c.GetPostForm("stock"); ok && !hasSKUs {
+            if stock, err := strconv.Atoi(raw); err == nil && stock >= 0 && stock != flower.Stock {
+                updates["stock"] = stock
+            }
         }
-        if categoryID > 0 {
-            flower.CategoryID = uint(categoryID)
+        if categoryID > 0 && uint(categoryID) != flower.CategoryID {
+            updates["category_id"] = categoryID
         }
-        if description != "" {
-            flower.Description = description
+        if description != "" && description != flower.Description {
+            updates["description"] = description
         }
-        if status == 0 || status == 1 {
-            flower.Status = status
+        if raw, ok := c.GetPostForm("status"); ok {
+            if status, err := strconv.Atoi(raw); err == nil && (status == 0 || status == 1) && status != flower.Status {
+                updates["status"] = status
+            }
         }
         
-        // 处理图片上传
-        form, err := c.MultipartForm()
-        if err == nil {
-            files := form.File["images"]
-            var imagePaths []string
-            
-            // 先删除旧图片
-            db.Where("flower_id = ?", flower.ID).Delete(&models.FlowerImage{})
-            
-            // 保存新图片
-            for _, file := range files {
-                filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), file.Filename)
-                filepath := path.Join("uploads", filename)
-                
-                if err := c.SaveUploadedFile(file, filepath); err != nil {
-                    continue
-                }
-                
-                image := models.FlowerImage{
-                    FlowerID: flower.ID,
-                    Path:     filepath,
-                }
-                if err := db.Create(&image).Error; err == nil {
-                    imagePaths = append(imagePaths, filepath)
-                }
+        // 上传了新图片时整体替换原有图片：先写入新图片文件，在事务中替换图片记录并保存鲜花，
+        // 提交后再删除旧图片文件；事务失败时删除新写入的文件，旧图片保持不变
+        var staged []models.FlowerImage
+        if len(images) > 0 {
+            if staged, err = stageImages(c, store, images, 0); err != nil {
+                log.Printf("save image for flower %d failed: %v", flower.ID, err)
+                jsonResponse(c, http.StatusInternalServerError, "保存图片失败", nil)
+                return
             }
-            
-            if len(imagePaths) > 0 {
-				flower.Images = []models.FlowerImage{}
-				for _, path := range imagePaths {
-					flower.Images = append(flower.Images, models.FlowerImage{Path: path})
-				}
+            for i := range staged {
+                staged[i].FlowerID = flower.ID
             }
         }
         
-        if err := db.Save(&flower).Error; err != nil {
+        err = db.Transaction(func(tx *gorm.DB) error {
+            if len(updates) > 0 {
+                if err := tx.Model(&models.Flower{}).Where("id = ?", flower.ID).Updates(updates).Error; err != nil {
+                    return err
+                }
+            }
+            if len(staged) > 0 {
+                if err := tx.Unscoped().Where("flower_id = ?", flower.ID).Delete(&models.FlowerImage{}).Error; err != nil {
+                    return err
+                }
+                // 原图片已删除，SKU 改为使用封面
+                if err := tx.Model(&models.FlowerSKU{}).Where("flower_id = ?", flower.ID).UpdateColumn("image_id", 0).Error; err != nil {
+                    return err
+                }
+                if err := tx.Create(&staged).Error; err != nil {
+                    return err
+                }
+            }
+            return syncFlowerCatalog(tx, store, flower.ID)
+        })
+        if err != nil {
+            discardImages(store, staged)
             c.JSON(http.StatusInternalServerError, models.ApiResponse{
                 Meta: models.Meta{
                     Msg:    "更新鲜花失败",
@@ -821,6 +1096,14 @@ func merchantUpdateFlowerHandler(db *gorm.DB) gin.HandlerFunc {
             })
             return
         }
+        if len(staged) > 0 {
+            discardImages(store, flower.Images)
+        }
+        if flower, err = findMerchantFlower(db, merchantID, flower.ID); err != nil {
+            jsonResponse(c, http.StatusInternalServerError, "更新鲜花失败", nil)
+            return
+        }
+        resolveFlowerImages(store, &flower)
         
         c.JSON(http.StatusOK, models.ApiResponse{
             Message: flower,
@@ -833,14 +1116,13 @@ func merchantUpdateFlowerHandler(db *gorm.DB) gin.HandlerFunc {
 }
 
 // 商家更新鲜花状态
-func merchantUpdateFlowerStatusHandler(db *gorm.DB) gin.HandlerFunc {
+func merchantUpdateFlowerStatusHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
     return func(c *gin.Context) {
-        //merchantID := c.MustGet("merchantID").(uint)
-		merchantID := uint(1) // 临时使用固定值
+        merchantID := c.MustGet("merchantID").(uint)
         flowerID := c.Param("id")
         
         var req struct {
-            Status int `json:"status" binding:"required,oneof=0 1"`
+            Status *int `json:"status" binding:"required,oneof=0 1"` // 0 为下架，不能用零值判断必填
         }
         
         if err := c.ShouldBindJSON(&req); err != nil {
@@ -853,8 +1135,8 @@ func merchantUpdateFlowerStatusHandler(db *gorm.DB) gin.HandlerFunc {
             return
         }
         
-        var flower models.Flower
-        if err := db.Where("id = ? AND merchant_id = ?", flowerID, merchantID).First(&flower).Error; err != nil {
+        flower, err := findMerchantFlower(db, merchantID, flowerID)
+        if err != nil {
             c.JSON(http.StatusNotFound, models.ApiResponse{
                 Meta: models.Meta{
                     Msg:    "鲜花不存在或无权访问",
@@ -864,8 +1146,15 @@ func merchantUpdateFlowerStatusHandler(db *gorm.DB) gin.HandlerFunc {
             return
         }
         
-        flower.Status = req.Status
-        if err := db.Save(&flower).Error; err != nil {
+        // 只更新状态列，整行保存会覆盖并发下单扣减后的库存和按规格计算的价格；
+        // 下架后在同一事务中从公共商品目录移除，同步失败时状态不变
+        err = db.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Model(&models.Flower{}).Where("id = ?", flower.ID).Update("status", *req.Status).Error; err != nil {
+                return err
+            }
+            return syncFlowerCatalog(tx, store, flower.ID)
+        })
+        if err != nil {
             c.JSON(http.StatusInternalServerError, models.ApiResponse{
                 Meta: models.Meta{
                     Msg:    "更新状态失败",
@@ -874,6 +1163,8 @@ func merchantUpdateFlowerStatusHandler(db *gorm.DB) gin.HandlerFunc {
             })
             return
         }
+        flower.Status = *req.Status
+        resolveFlowerImages(store, &flower)
         
         c.JSON(http.StatusOK, models.ApiResponse{
             Message: flower,
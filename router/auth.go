@@ -0,0 +1,196 @@
+package router
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/sql"
+	"github.com/LookAt-MeNow/flowers/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tokenTTL 令牌有效期
+func tokenTTL(cfg *sql.Config) time.Duration {
+	return time.Duration(cfg.Auth.TokenExpire) * time.Hour
+}
+
+// abortUnauthorized 返回 401 并终止后续处理
+func abortUnauthorized(c *gin.Context, msg string) {
+	abortResponse(c, http.StatusUnauthorized, msg)
+}
+
+// parseBearerToken 从 Authorization 头中解析令牌并校验角色
+func parseBearerToken(c *gin.Context, cfg *sql.Config, role string) (*utils.Claims, bool) {
+	header := c.GetHeader("Authorization")
+	tokenString := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	if tokenString == "" {
+		abortUnauthorized(c, "请先登录")
+		return nil, false
+	}
+
+	claims, err := utils.ParseToken(cfg.Auth.JWTSecret, tokenString)
+	if err != nil || claims.Role != role {
+		abortUnauthorized(c, "登录已失效，请重新登录")
+		return nil, false
+	}
+	return claims, true
+}
+
+// MerchantAuthMiddleware 商家鉴权中间件，校验令牌后把 merchantID 写入上下文
+// 每次请求都会核对商家状态和令牌版本，被禁用商家的旧令牌立即失效
+func MerchantAuthMiddleware(cfg *sql.Config, db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := parseBearerToken(c, cfg, utils.RoleMerchant)
+		if !ok {
+			return
+		}
+
+		var merchant models.Merchant
+		if err := db.Select("id", "status", "status_reason", "token_version").First(&merchant, claims.ID).Error; err != nil {
+			abortUnauthorized(c, "商家不存在")
+			return
+		}
+		if merchant.TokenVersion != claims.Version {
+			abortUnauthorized(c, "登录已失效，请重新登录")
+			return
+		}
+		if merchant.Status != models.MerchantStatusNormal {
+			abortResponse(c, http.StatusForbidden, merchantStatusMessage(merchant))
+			return
+		}
+
+		c.Set("merchantID", claims.ID)
+		c.Next()
+	}
+}
+
+// UserAuthMiddleware 买家鉴权中间件，校验令牌后把 userID 写入上下文
+func UserAuthMiddleware(cfg *sql.Config, db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := parseBearerToken(c, cfg, utils.RoleUser)
+		if !ok {
+			return
+		}
+
+		var user models.User
+		if err := db.Select("id", "status").First(&user, claims.ID).Error; err != nil {
+			abortUnauthorized(c, "用户不存在")
+			return
+		}
+		if user.Status != 1 {
+			abortResponse(c, http.StatusForbidden, "账号已被禁用")
+			return
+		}
+
+		c.Set("userID", claims.ID)
+		c.Next()
+	}
+}
+
+// merchantStatusMessage 商家不可用时的提示信息
+func merchantStatusMessage(m models.Merchant) string {
+	var msg string
+	switch m.Status {
+	case models.MerchantStatusPending:
+		return "商家账号正在审核中"
+	case models.MerchantStatusRejected:
+		msg = "商家入驻申请未通过"
+	default:
+		msg = "商家账号已被禁用"
+	}
+	if m.StatusReason != "" {
+		msg += "：" + m.StatusReason
+	}
+	return msg
+}
+
+// AdminAuthMiddleware 管理端鉴权中间件
+// 校验令牌后加载管理员及其角色权限，写入 adminID、adminRole 和 adminPermissions
+func AdminAuthMiddleware(cfg *sql.Config, db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := parseBearerToken(c, cfg, utils.RoleAdmin)
+		if !ok {
+			return
+		}
+
+		var admin models.Admin
+		if err := db.First(&admin, claims.ID).Error; err != nil {
+			abortUnauthorized(c, "管理员不存在")
+			return
+		}
+
+		permissions, err := loadRolePermissions(db, admin.Role)
+		if err != nil {
+			abortResponse(c, http.StatusInternalServerError, "加载权限失败")
+			return
+		}
+
+		c.Set("adminID", admin.ID)
+		c.Set("adminRole", admin.Role)
+		c.Set("adminPermissions", permissions)
+		c.Next()
+	}
+}
+
+// RequirePermission 要求当前管理员拥有指定权限，需放在 AdminAuthMiddleware 之后
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permissions, _ := c.Get("adminPermissions")
+		if set, ok := permissions.(map[string]bool); !ok || !set[perm] {
+			abortResponse(c, http.StatusForbidden, "没有操作权限")
+			return
+		}
+		c.Next()
+	}
+}
+
+// loadRolePermissions 查询角色拥有的权限编码，超级管理员拥有全部权限
+func loadRolePermissions(db *gorm.DB, roleName string) (map[string]bool, error) {
+	permissions := make(map[string]bool)
+	if roleName == models.RoleSuperAdmin {
+		var all []models.Permission
+		if err := db.Find(&all).Error; err != nil {
+			return nil, err
+		}
+		for _, p := range all {
+			permissions[p.Code] = true
+		}
+		for _, p := range models.DefaultPermissions {
+			permissions[p.Code] = true
+		}
+		return permissions, nil
+	}
+
+	var role models.Role
+	err := db.Preload("Permissions").Where("name = ?", roleName).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return permissions, nil // 角色已被删除，视为无权限
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range role.Permissions {
+		permissions[p.Code] = true
+	}
+	return permissions, nil
+}
+
+// upgradePassword 把明文/MD5 等旧格式密码重新哈希为 bcrypt，失败时只记录日志不影响登录
+func upgradePassword(db *gorm.DB, account interface{}, plainpwd string) {
+	hashed, err := utils.HashPassword(plainpwd)
+	if err != nil {
+		log.Printf("rehash password failed: %v", err)
+		return
+	}
+	if err := db.Model(account).Updates(map[string]interface{}{
+		"password": hashed,
+		"salt":     "",
+	}).Error; err != nil {
+		log.Printf("save rehashed password failed: %v", err)
+	}
+}
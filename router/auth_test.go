@@ -0,0 +1,64 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMerchantLogin(t *testing.T) {
+	s := newTestServer(t)
+	s.registerMerchant("pending")
+
+	// 未审核的商家不能登录
+	resp := s.call(http.MethodPost, "/api/public/v1/auth/merchants/login", "", gin.H{"username": "pending", "password": testPassword})
+	expect(t, resp, http.StatusForbidden)
+
+	_, token := s.merchant("shop")
+	resp = s.call(http.MethodGet, "/api/public/v1/merchants/flowers", token, nil)
+	expect(t, resp, http.StatusOK)
+
+	resp = s.call(http.MethodPost, "/api/public/v1/auth/merchants/login", "", gin.H{"username": "shop", "password": "wrong-password"})
+	expect(t, resp, http.StatusUnauthorized)
+	resp = s.call(http.MethodPost, "/api/public/v1/auth/merchants/login", "", gin.H{"username": "nobody", "password": testPassword})
+	expect(t, resp, http.StatusUnauthorized)
+}
+
+func TestMerchantRegisterDuplicate(t *testing.T) {
+	s := newTestServer(t)
+	s.registerMerchant("shop")
+
+	resp := s.call(http.MethodPost, "/api/public/v1/auth/merchants/register", "", gin.H{
+		"username": "shop", "password": testPassword, "shop_name": "另一家",
+		"email": "other@example.com", "phone": "13900000000",
+	})
+	if resp.Code == http.StatusCreated {
+		t.Fatal("duplicate username registered")
+	}
+}
+
+func TestAdminLogin(t *testing.T) {
+	s := newTestServer(t)
+	token := s.adminToken()
+
+	resp := s.call(http.MethodGet, "/api/public/v1/admin/merchants", token, nil)
+	expect(t, resp, http.StatusOK)
+
+	resp = s.call(http.MethodPost, "/api/public/v1/auth/admin/login", "", gin.H{"username": testAdminUsername, "password": "wrong-password"})
+	expect(t, resp, http.StatusUnauthorized)
+}
+
+func TestAuthMiddlewareRejectsMissingOrForeignToken(t *testing.T) {
+	s := newTestServer(t)
+	_, merchantToken := s.merchant("shop")
+	userToken := s.user("buyer")
+
+	expect(t, s.call(http.MethodGet, "/api/public/v1/merchants/flowers", "", nil), http.StatusUnauthorized)
+	expect(t, s.call(http.MethodGet, "/api/public/v1/merchants/flowers", "not-a-token", nil), http.StatusUnauthorized)
+
+	// 令牌角色必须与路由一致
+	expect(t, s.call(http.MethodGet, "/api/public/v1/merchants/flowers", userToken, nil), http.StatusUnauthorized)
+	expect(t, s.call(http.MethodGet, "/api/public/v1/my/cart", merchantToken, nil), http.StatusUnauthorized)
+	expect(t, s.call(http.MethodGet, "/api/public/v1/admin/merchants", merchantToken, nil), http.StatusUnauthorized)
+}
@@ -0,0 +1,449 @@
+package router
+
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------------------------------------买家端：购物车
+
+// 单个条目的最大购买数量
+const maxCartQuantity = 999
+
+var (
+	errCartProductNotFound = errors.New("商品不存在")
+	errCartOffShelf        = errors.New("商品已下架")
+	errCartAttrNotFound    = errors.New("商品规格不存在")
+	errCartInvalidItem     = errors.New("goods_id 和 flower_id 必须且只能填写一个")
+	errInsufficientStock   = errors.New("库存不足")
+)
+
+// cartLine 加入购物车的商品及数量，也用于登录时合并游客购物车
+type cartLine struct {
+	GoodsID  uint `json:"goods_id"`
+	AttrID   uint `json:"attr_id"`
+	FlowerID uint `json:"flower_id"`
+	SKUID    uint `json:"sku_id"` // 鲜花有规格时必填
+	Quantity int  `json:"quantity"`
+}
+
+// cartItemView 购物车条目及实时价格、库存
+type cartItemView struct {
+	ID         uint    `json:"id"`
+	GoodsID    uint    `json:"goods_id"`
+	AttrID     uint    `json:"attr_id"`
+	FlowerID   uint    `json:"flower_id"`
+	SKUID      uint    `json:"sku_id"`
+	MerchantID uint    `json:"merchant_id"` // 平台商品为 0
+	Name       string  `json:"name"`
+	AttrValue  string  `json:"attr_value"`
+	Image      string  `json:"image"`
+	Price      float64 `json:"price"`
+	Stock      int     `json:"stock"`
+	Quantity   int     `json:"quantity"`
+	Selected   bool    `json:"selected"`
+	OffShelf   bool    `json:"off_shelf"`    // 已下架或已删除
+	OutOfStock bool    `json:"out_of_stock"` // 库存不足
+}
+
+// Available 条目当前是否可以结算
+func (v cartItemView) Available() bool {
+	return !v.OffShelf && !v.OutOfStock
+}
+
+// resolveCartItems 批量查询商品和鲜花，计算每个条目的实时价格、库存和可售状态
+func resolveCartItems(db *gorm.DB, store storage.Storage, items []models.CartItem) ([]cartItemView, error) {
+	var goodsIDs, attrIDs, flowerIDs []uint
+	for _, item := range items {
+		if item.FlowerID != 0 {
+			flowerIDs = append(flowerIDs, item.FlowerID)
+		} else {
+			goodsIDs = append(goodsIDs, item.GoodsID)
+			if item.AttrID != 0 {
+				attrIDs = append(attrIDs, item.AttrID)
+			}
+		}
+	}
+
+	goodsMap := make(map[uint]models.Goods)
+	if len(goodsIDs) > 0 {
+		var goods []models.Goods
+		if err := db.Where("goods_id IN ?", goodsIDs).Find(&goods).Error; err != nil {
+			return nil, err
+		}
+		for _, g := range goods {
+			goodsMap[g.GoodsID] = g
+		}
+	}
+
+	attrMap := make(map[uint]models.GoodsAttr)
+	if len(attrIDs) > 0 {
+		var attrs []models.GoodsAttr
+		if err := db.Where("attr_id IN ?", attrIDs).Find(&attrs).Error; err != nil {
+			return nil, err
+		}
+		for _, a := range attrs {
+			attrMap[a.AttrID] = a
+		}
+	}
+
+	flowerMap := make(map[uint]models.Flower)
+	activeMerchants := make(map[uint]bool)
+	if len(flowerIDs) > 0 {
+		var flowers []models.Flower
+		if err := db.Scopes(preloadVariants).Preload("Images", orderedImages).Where("id IN ?", flowerIDs).Find(&flowers).Error; err != nil {
+			return nil, err
+		}
+		merchantIDs := make([]uint, 0, len(flowers))
+		for _, f := range flowers {
+			flowerMap[f.ID] = f
+			merchantIDs = append(merchantIDs, f.MerchantID)
+		}
+		// 与 flowerPublished 一致，商家状态不正常时鲜花按下架处理
+		var active []uint
+		if err := db.Model(&models.Merchant{}).Where("id IN ? AND status = ?", merchantIDs, models.MerchantStatusNormal).Pluck("id", &active).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range active {
+			activeMerchants[id] = true
+		}
+	}
+
+	views := make([]cartItemView, 0, len(items))
+	for _, item := range items {
+		view := cartItemView{
+			ID:       item.ID,
+			GoodsID:  item.GoodsID,
+			AttrID:   item.AttrID,
+			FlowerID: item.FlowerID,
+			SKUID:    item.SKUID,
+			Quantity: item.Quantity,
+			Selected: item.Selected,
+		}
+
+		if item.FlowerID != 0 {
+			flower, ok := flowerMap[item.FlowerID]
+			published := ok && flower.Status == 1 && activeMerchants[flower.MerchantID]
+			view.OffShelf = !published
+			if ok {
+				view.MerchantID = flower.MerchantID
+				view.Name = flower.Name
+				view.Price = flower.Price
+				view.Stock = flower.Stock
+				if cover, ok := flowerCover(store, flower); ok {
+					view.Image = cover.PicsSma
+				}
+				// 有规格时价格、库存、图片以 SKU 为准，SKU 已删除或未选择规格时不能结算
+				if len(flower.SKUs) > 0 || item.SKUID != 0 {
+					view.OffShelf = true
+					for _, sku := range flower.SKUs {
+						if sku.ID != item.SKUID {
+							continue
+						}
+						view.OffShelf = !published
+						view.AttrValue = sku.Spec
+						view.Price = sku.Price
+						view.Stock = sku.Stock
+						if img, ok := skuImage(store, flower, sku); ok {
+							view.Image = img.PicsSma
+						}
+					}
+				}
+			}
+		} else {
+			goods, ok := goodsMap[item.GoodsID]
+			if !ok {
+				view.OffShelf = true
+			} else {
+				view.Name = goods.GoodsName
+				view.Price = goods.GoodsPrice
+				view.Stock = int(goods.GoodsNumber)
+				view.Image = goods.GoodsSmallLogo
+			}
+			if item.AttrID != 0 {
+				attr, ok := attrMap[item.AttrID]
+				if !ok || attr.GoodsID != item.GoodsID {
+					view.OffShelf = true // 规格已删除
+				} else {
+					view.AttrValue = attr.AttrValue
+					view.Price += attr.AddPrice
+				}
+			}
+		}
+
+		view.OutOfStock = !view.OffShelf && view.Stock < item.Quantity
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// normalizeCartLine 由鲜花同步到目录的商品按鲜花处理，库存和上下架以鲜花为准
+func normalizeCartLine(db *gorm.DB, line cartLine) cartLine {
+	if line.GoodsID == 0 || line.FlowerID != 0 {
+		return line
+	}
+	var goods models.Goods
+	if err := db.Select("goods_id", "flower_id").Where("goods_id = ?", line.GoodsID).First(&goods).Error; err == nil && goods.FlowerID != 0 {
+		line.FlowerID, line.GoodsID, line.AttrID = goods.FlowerID, 0, 0
+	}
+	return line
+}
+
+// checkCartLine 校验要加入购物车的商品是否存在且在售，返回当前库存；有规格的鲜花返回 SKU 的库存
+func checkCartLine(db *gorm.DB, line cartLine) (int, error) {
+	if (line.GoodsID == 0) == (line.FlowerID == 0) {
+		return 0, errCartInvalidItem
+	}
+
+	if line.FlowerID != 0 {
+		var flower models.Flower
+		if err := db.Select("id", "merchant_id", "status", "stock").First(&flower, line.FlowerID).Error; err != nil {
+			return 0, errCartProductNotFound
+		}
+		published, err := flowerPublished(db, flower)
+		if err != nil {
+			return 0, err
+		}
+		if !published {
+			return 0, errCartOffShelf
+		}
+		hasSKUs, err := flowerHasSKUs(db, flower.ID)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case hasSKUs && line.SKUID == 0:
+			return 0, errFlowerSKURequired
+		case line.SKUID != 0:
+			sku, err := findFlowerSKU(db, flower.ID, line.SKUID)
+			if err == errFlowerSKUNotFound {
+				return 0, errCartAttrNotFound
+			}
+			return sku.Stock, err
+		}
+		return flower.Stock, nil
+	}
+
+	var goods models.Goods
+	if err := db.Where("goods_id = ?", line.GoodsID).First(&goods).Error; err != nil {
+		return 0, errCartProductNotFound
+	}
+	if line.AttrID != 0 {
+		var count int64
+		db.Model(&models.GoodsAttr{}).Where("attr_id = ? AND goods_id = ?", line.AttrID, line.GoodsID).Count(&count)
+		if count == 0 {
+			return 0, errCartAttrNotFound
+		}
+	}
+	return int(goods.GoodsNumber), nil
+}
+
+// addCartLine 加入购物车，同一商品同一规格合并数量
+// clamp 为 true 时数量超过库存会被截断，用于合并游客购物车；否则返回库存不足
+func addCartLine(db *gorm.DB, userID uint, line cartLine, clamp bool) (*models.CartItem, error) {
+	line = normalizeCartLine(db, line)
+	stock, err := checkCartLine(db, line)
+	if err != nil {
+		return nil, err
+	}
+	if line.FlowerID != 0 {
+		line.GoodsID, line.AttrID = 0, 0
+	} else {
+		line.SKUID = 0
+	}
+
+	var item models.CartItem
+	err = db.Where("user_id = ? AND goods_id = ? AND attr_id = ? AND flower_id = ? AND sku_id = ?",
+		userID, line.GoodsID, line.AttrID, line.FlowerID, line.SKUID).First(&item).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	quantity := item.Quantity + line.Quantity
+	if quantity > maxCartQuantity {
+		quantity = maxCartQuantity
+	}
+	if quantity > stock {
+		if !clamp {
+			return nil, errInsufficientStock
+		}
+		quantity = stock
+	}
+	if quantity < 1 {
+		return nil, errInsufficientStock
+	}
+
+	item.UserID = userID
+	item.GoodsID = line.GoodsID
+	item.AttrID = line.AttrID
+	item.FlowerID = line.FlowerID
+	item.SKUID = line.SKUID
+	item.Quantity = quantity
+	item.Selected = true
+	if err := db.Save(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// mergeGuestCart 登录时把游客购物车合并到账号购物车，无效条目直接忽略
+func mergeGuestCart(db *gorm.DB, userID uint, lines []cartLine) {
+	for _, line := range lines {
+		if line.Quantity < 1 {
+			continue
+		}
+		addCartLine(db, userID, line, true)
+	}
+}
+
+// cartErrorStatus 购物车业务错误对应的 HTTP 状态码
+func cartErrorStatus(err error) int {
+	switch err {
+	case errCartProductNotFound, errCartAttrNotFound:
+		return http.StatusNotFound
+	case errCartOffShelf, errCartInvalidItem, errInsufficientStock, errFlowerSKURequired:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// 购物车列表
+func cartListHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var items []models.CartItem
+		if err := db.Where("user_id = ?", c.MustGet("userID").(uint)).Order("id DESC").Find(&items).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取购物车失败", nil)
+			return
+		}
+		views, err := resolveCartItems(db, store, items)
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取购物车失败", nil)
+			return
+		}
+
+		// 只统计已勾选且可结算的条目
+		var selectedCount int
+		var selectedTotal float64
+		for _, v := range views {
+			if v.Selected && v.Available() {
+				selectedCount += v.Quantity
+				selectedTotal += v.Price * float64(v.Quantity)
+			}
+		}
+
+		jsonResponse(c, http.StatusOK, "获取成功", gin.H{
+			"items":          views,
+			"selected_count": selectedCount,
+			"selected_total": roundPrice(selectedTotal),
+		})
+	}
+}
+
+// 加入购物车
+func cartAddHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req cartLine
+		if err := c.ShouldBindJSON(&req); err != nil || req.Quantity < 1 || req.Quantity > maxCartQuantity {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		item, err := addCartLine(db, c.MustGet("userID").(uint), req, false)
+		if err != nil {
+			status := cartErrorStatus(err)
+			msg := err.Error()
+			if status == http.StatusInternalServerError {
+				msg = "加入购物车失败"
+			}
+			jsonResponse(c, status, msg, nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "加入购物车成功", item)
+	}
+}
+
+// 修改购物车条目数量
+func cartUpdateHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Quantity int `json:"quantity" binding:"required,min=1,max=999"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		var item models.CartItem
+		if err := db.Where("id = ? AND user_id = ?", c.Param("id"), c.MustGet("userID").(uint)).First(&item).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "购物车条目不存在", nil)
+			return
+		}
+		stock, err := checkCartLine(db, cartLine{GoodsID: item.GoodsID, AttrID: item.AttrID, FlowerID: item.FlowerID, SKUID: item.SKUID})
+		if err != nil {
+			jsonResponse(c, cartErrorStatus(err), err.Error(), nil)
+			return
+		}
+		if req.Quantity > stock {
+			jsonResponse(c, http.StatusBadRequest, errInsufficientStock.Error(), gin.H{"stock": stock})
+			return
+		}
+
+		if err := db.Model(&item).Update("quantity", req.Quantity).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "更新购物车失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "更新成功", item)
+	}
+}
+
+// 删除购物车条目
+func cartDeleteHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Where("id = ? AND user_id = ?", c.Param("id"), c.MustGet("userID").(uint)).Delete(&models.CartItem{})
+		if result.Error != nil {
+			jsonResponse(c, http.StatusInternalServerError, "删除失败", nil)
+			return
+		}
+		if result.RowsAffected == 0 {
+			jsonResponse(c, http.StatusNotFound, "购物车条目不存在", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "删除成功", nil)
+	}
+}
+
+// 勾选/取消勾选，ids 为空时作用于全部条目
+func cartSelectHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			IDs      []uint `json:"ids"`
+			Selected *bool  `json:"selected" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		query := db.Model(&models.CartItem{}).Where("user_id = ?", c.MustGet("userID").(uint))
+		if len(req.IDs) > 0 {
+			query = query.Where("id IN ?", req.IDs)
+		}
+		if err := query.Update("selected", *req.Selected).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "更新失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "更新成功", nil)
+	}
+}
+
+// roundPrice 金额保留两位小数
+func roundPrice(v float64) float64 {
+	return math.Round(v*100) / 100
+}
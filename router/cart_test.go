@@ -0,0 +1,94 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/gin-gonic/gin"
+)
+
+// cartView 购物车列表的响应
+type cartView struct {
+	Items         []cartItemView `json:"items"`
+	SelectedCount int            `json:"selected_count"`
+	SelectedTotal float64        `json:"selected_total"`
+}
+
+func (s *testServer) cart(token string) cartView {
+	s.t.Helper()
+	resp := s.call(http.MethodGet, "/api/public/v1/my/cart", token, nil)
+	expect(s.t, resp, http.StatusOK)
+	var view cartView
+	resp.decode(s.t, &view)
+	return view
+}
+
+func TestCartAddUpdateDelete(t *testing.T) {
+	s := newTestServer(t)
+	merchant, _ := s.merchant("shop")
+	flower := s.flower(merchant.ID, "红玫瑰", 19.9, 5)
+	token := s.user("buyer")
+
+	resp := s.call(http.MethodPost, "/api/public/v1/my/cart", token, gin.H{"flower_id": flower.ID, "quantity": 2})
+	expect(t, resp, http.StatusOK)
+	var item models.CartItem
+	resp.decode(t, &item)
+
+	// 重复加入累加数量
+	expect(t, s.call(http.MethodPost, "/api/public/v1/my/cart", token, gin.H{"flower_id": flower.ID, "quantity": 1}), http.StatusOK)
+	view := s.cart(token)
+	if len(view.Items) != 1 || view.Items[0].Quantity != 3 {
+		t.Fatalf("items = %+v, want one line of 3", view.Items)
+	}
+	if view.SelectedCount != 3 || view.SelectedTotal != 59.7 {
+		t.Fatalf("selected = %d / %v, want 3 / 59.7", view.SelectedCount, view.SelectedTotal)
+	}
+
+	path := fmt.Sprintf("/api/public/v1/my/cart/%d", item.ID)
+	expect(t, s.call(http.MethodPut, path, token, gin.H{"quantity": 6}), http.StatusBadRequest)
+	expect(t, s.call(http.MethodPut, path, token, gin.H{"quantity": 4}), http.StatusOK)
+	if got := s.cart(token).Items[0].Quantity; got != 4 {
+		t.Fatalf("quantity = %d, want 4", got)
+	}
+
+	// 其他买家不能修改
+	other := s.user("other")
+	expect(t, s.call(http.MethodPut, path, other, gin.H{"quantity": 1}), http.StatusNotFound)
+
+	expect(t, s.call(http.MethodDelete, path, token, nil), http.StatusOK)
+	if got := len(s.cart(token).Items); got != 0 {
+		t.Fatalf("items = %d after delete, want 0", got)
+	}
+}
+
+func TestCartAddRejectsUnavailableFlower(t *testing.T) {
+	s := newTestServer(t)
+	merchant, _ := s.merchant("shop")
+	token := s.user("buyer")
+
+	flower := s.flower(merchant.ID, "红玫瑰", 19.9, 2)
+	expect(t, s.call(http.MethodPost, "/api/public/v1/my/cart", token, gin.H{"flower_id": flower.ID, "quantity": 3}), http.StatusBadRequest)
+	expect(t, s.call(http.MethodPost, "/api/public/v1/my/cart", token, gin.H{"flower_id": 9999, "quantity": 1}), http.StatusNotFound)
+	expect(t, s.call(http.MethodPost, "/api/public/v1/my/cart", token, gin.H{"flower_id": flower.ID, "goods_id": 1, "quantity": 1}), http.StatusBadRequest)
+
+	offShelf := s.flower(merchant.ID, "白百合", 29, 5)
+	s.db.Model(&offShelf).Update("status", 0)
+	expect(t, s.call(http.MethodPost, "/api/public/v1/my/cart", token, gin.H{"flower_id": offShelf.ID, "quantity": 1}), http.StatusBadRequest)
+}
+
+func TestCartMarksDisabledMerchantOffShelf(t *testing.T) {
+	s := newTestServer(t)
+	merchant, _ := s.merchant("shop")
+	flower := s.flower(merchant.ID, "红玫瑰", 19.9, 5)
+	token := s.user("buyer")
+	expect(t, s.call(http.MethodPost, "/api/public/v1/my/cart", token, gin.H{"flower_id": flower.ID, "quantity": 1}), http.StatusOK)
+
+	s.db.Model(&merchant).Update("status", models.MerchantStatusDisabled)
+	view := s.cart(token)
+	if !view.Items[0].OffShelf || view.SelectedCount != 0 {
+		t.Fatalf("item = %+v, selected = %d; want off shelf and nothing selected", view.Items[0], view.SelectedCount)
+	}
+	expect(t, s.call(http.MethodPost, "/api/public/v1/my/cart", token, gin.H{"flower_id": flower.ID, "quantity": 1}), http.StatusBadRequest)
+}
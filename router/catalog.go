@@ -0,0 +1,151 @@
+package router
+
+import (
+	"errors"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/storage"
+	"gorm.io/gorm"
+)
+
+// --------------------------------------商品目录同步
+// 商家的鲜花上架后写入 goods、goods_search、goods_detail、goods_pictures，
+// 与平台商品一起出现在公共搜索和详情中；下架或商家停用后从这些表中删除
+
+// goodsStatePublished 商品审核状态：已发布
+const goodsStatePublished = 2
+
+// syncFlowerCatalog 按鲜花当前状态同步公共商品目录
+func syncFlowerCatalog(tx *gorm.DB, store storage.Storage, flowerID uint) error {
+	var flower models.Flower
+	err := tx.Unscoped().Preload("Images", orderedImages).First(&flower, flowerID).Error
+	if err != nil {
+		return err
+	}
+
+	var goods models.Goods
+	err = tx.Where("flower_id = ?", flower.ID).First(&goods).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	exists := err == nil
+
+	published, err := flowerPublished(tx, flower)
+	if err != nil {
+		return err
+	}
+	if !published {
+		if !exists {
+			return nil
+		}
+		return removeCatalogGoods(tx, goods.GoodsID)
+	}
+
+	cover, _ := flowerCover(store, flower)
+	goods.FlowerID = flower.ID
+	goods.MerchantID = flower.MerchantID
+	goods.CatID = flower.CategoryID
+	goods.GoodsName = flower.Name
+	goods.GoodsPrice = flower.Price
+	goods.GoodsNumber = uint(max(flower.Stock, 0))
+	goods.GoodsBigLogo = cover.PicsBig
+	goods.GoodsSmallLogo = cover.PicsSma
+	if err := tx.Save(&goods).Error; err != nil {
+		return err
+	}
+
+	search := models.Goods_search{ID: goods.GoodsID, Name: goods.GoodsName}
+	if err := tx.Table("goods_search").Save(&search).Error; err != nil {
+		return err
+	}
+	detail := models.Goods_detail{
+		Goods:          goods,
+		GoodsIntroduce: flower.Description,
+		GoodsState:     goodsStatePublished,
+		IsDel:          "0",
+	}
+	if err := tx.Omit("Pics", "Attrs").Save(&detail).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("goods_id = ?", goods.GoodsID).Delete(&models.GoodsPicture{}).Error; err != nil {
+		return err
+	}
+	for _, img := range flower.Images {
+		img.ResolveURLs(store.URL)
+		pic := models.GoodsPicture{GoodsID: goods.GoodsID, PicsBig: img.PicsBig, PicsMid: img.PicsMid, PicsSma: img.PicsSma}
+		if err := tx.Create(&pic).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flowerPublished 鲜花上架且商家状态正常时才对买家可见
+func flowerPublished(tx *gorm.DB, flower models.Flower) (bool, error) {
+	if flower.DeletedAt.Valid || flower.Status != 1 {
+		return false, nil
+	}
+	var merchant models.Merchant
+	if err := tx.Select("id", "status").First(&merchant, flower.MerchantID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return merchant.Status == models.MerchantStatusNormal, nil
+}
+
+// removeCatalogGoods 从公共商品目录删除
+func removeCatalogGoods(tx *gorm.DB, goodsID uint) error {
+	if err := tx.Where("goods_id = ?", goodsID).Delete(&models.GoodsPicture{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Table("goods_search").Where("goods_id = ?", goodsID).Delete(&models.Goods_search{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("goods_id = ?", goodsID).Delete(&models.Goods_detail{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("goods_id = ?", goodsID).Delete(&models.Goods{}).Error
+}
+
+// syncMerchantCatalog 商家状态变化后同步其全部鲜花
+func syncMerchantCatalog(tx *gorm.DB, store storage.Storage, merchantID uint) error {
+	var ids []uint
+	if err := tx.Model(&models.Flower{}).Where("merchant_id = ?", merchantID).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := syncFlowerCatalog(tx, store, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncCatalogStock 鲜花库存变化后更新目录中的库存
+func syncCatalogStock(tx *gorm.DB, flowerID uint) error {
+	return tx.Model(&models.Goods{}).Where("flower_id = ?", flowerID).
+		UpdateColumn("goods_number", gorm.Expr("(SELECT stock FROM flowers WHERE id = ?)", flowerID)).Error
+}
+
+// SyncFlowerCatalog 重新同步已上架的鲜花到公共商品目录，启动时调用；
+// 目录中保存的是图片访问地址，图片地址前缀修改后也靠这里刷新
+func SyncFlowerCatalog(db *gorm.DB, store storage.Storage) error {
+	var ids []uint
+	err := db.Model(&models.Flower{}).
+		Where("status = 1 OR EXISTS (SELECT 1 FROM goods WHERE goods.flower_id = flowers.id)").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return syncFlowerCatalog(tx, store, id)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
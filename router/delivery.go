@@ -0,0 +1,518 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --------------------------------------配送排期
+
+const (
+	deliveryDateLayout = "2006-01-02"
+	deliveryTimeLayout = "15:04"
+)
+
+var (
+	errDeliverySlotRequired = errors.New("请选择配送日期和时段")
+	errDeliveryDateInvalid  = errors.New("配送日期格式错误")
+	errDeliveryDatePast     = errors.New("配送日期已过")
+	errDeliveryDateTooFar   = errors.New("超出可预约的配送日期")
+	errDeliveryBlackout     = errors.New("该日期暂停配送")
+	errDeliverySlotNotFound = errors.New("配送时段不存在")
+	errDeliveryCutoff       = errors.New("已过当日配送截单时间")
+	errDeliverySlotStarted  = errors.New("该配送时段已开始")
+	errDeliverySlotFull     = errors.New("该配送时段已约满")
+	errDeliveryDayFull      = errors.New("当天配送已约满")
+)
+
+// deliveryErrors 配送相关的业务错误，均为参数问题
+var deliveryErrors = []error{
+	errDeliverySlotRequired, errDeliveryDateInvalid, errDeliveryDatePast, errDeliveryDateTooFar, errDeliveryBlackout,
+	errDeliverySlotNotFound, errDeliveryCutoff, errDeliverySlotStarted, errDeliverySlotFull, errDeliveryDayFull,
+}
+
+func isDeliveryError(err error) bool {
+	for _, e := range deliveryErrors {
+		if err == e {
+			return true
+		}
+	}
+	return false
+}
+
+// 占用配送名额的订单状态，已取消和已退款的订单不计入
+var deliveryActiveStatuses = []string{
+	models.OrderStatusPendingPayment,
+	models.OrderStatusPaid,
+	models.OrderStatusPreparing,
+	models.OrderStatusDelivering,
+	models.OrderStatusCompleted,
+}
+
+// validClock 校验 HH:MM 格式
+func validClock(s string) bool {
+	_, err := time.Parse(deliveryTimeLayout, s)
+	return err == nil && len(s) == 5
+}
+
+// loadDeliverySetting 读取商家配送设置，未设置时使用默认值
+func loadDeliverySetting(db *gorm.DB, merchantID uint) (models.DeliverySetting, error) {
+	setting := models.DeliverySetting{MerchantID: merchantID, MaxAdvanceDays: 30}
+	err := db.Where("merchant_id = ?", merchantID).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return setting, nil
+	}
+	return setting, err
+}
+
+// checkDeliveryDate 校验配送日期是否在可预约范围内且不是停配日期
+func checkDeliveryDate(db *gorm.DB, setting models.DeliverySetting, date string, now time.Time) error {
+	day, err := time.ParseInLocation(deliveryDateLayout, date, now.Location())
+	if err != nil {
+		return errDeliveryDateInvalid
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if day.Before(today) {
+		return errDeliveryDatePast
+	}
+	if day.After(today.AddDate(0, 0, setting.MaxAdvanceDays)) {
+		return errDeliveryDateTooFar
+	}
+	if day.Equal(today) && (setting.SameDayCutoff == "" || now.Format(deliveryTimeLayout) >= setting.SameDayCutoff) {
+		return errDeliveryCutoff
+	}
+
+	var blackouts int64
+	if err := db.Model(&models.DeliveryBlackout{}).
+		Where("merchant_id = ? AND date = ?", setting.MerchantID, date).
+		Count(&blackouts).Error; err != nil {
+		return err
+	}
+	if blackouts > 0 {
+		return errDeliveryBlackout
+	}
+	return nil
+}
+
+// deliveryBookings 统计某天各时段已占用的订单数，返回按时段的数量和全天合计
+func deliveryBookings(db *gorm.DB, merchantID uint, date string) (map[uint]int64, int64, error) {
+	var rows []struct {
+		DeliverySlotID uint
+		Count          int64
+	}
+	err := db.Model(&models.Order{}).
+		Select("delivery_slot_id, COUNT(*) AS count").
+		Where("merchant_id = ? AND delivery_date = ? AND status IN ?", merchantID, date, deliveryActiveStatuses).
+		Group("delivery_slot_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	bySlot := make(map[uint]int64, len(rows))
+	var total int64
+	for _, r := range rows {
+		bySlot[r.DeliverySlotID] = r.Count
+		total += r.Count
+	}
+	return bySlot, total, nil
+}
+
+// slotStarted 当日配送时段是否已经开始
+func slotStarted(slot models.DeliverySlot, date string, now time.Time) bool {
+	return date == now.Format(deliveryDateLayout) && now.Format(deliveryTimeLayout) >= slot.StartTime
+}
+
+// reserveDeliverySlot 下单时在事务内校验并占用配送时段
+// 先锁商家行，同一商家的并发下单依次计数；商家未保存配送设置时也有行可锁。
+// 订单数用加锁读统计，MySQL 可重复读下读到其他事务已提交的订单而不是事务快照，避免超出时段容量和每日上限
+func reserveDeliverySlot(tx *gorm.DB, merchantID uint, date string, slotID uint, now time.Time) (models.DeliverySlot, error) {
+	var slot models.DeliverySlot
+	var merchant models.Merchant
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&merchant, merchantID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return slot, errDeliverySlotNotFound
+	} else if err != nil {
+		return slot, err
+	}
+	setting, err := loadDeliverySetting(tx, merchantID)
+	if err != nil {
+		return slot, err
+	}
+	if err := checkDeliveryDate(tx, setting, date, now); err != nil {
+		return slot, err
+	}
+
+	err = tx.Where("id = ? AND merchant_id = ? AND enabled = ?", slotID, merchantID, true).First(&slot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return slot, errDeliverySlotNotFound
+	} else if err != nil {
+		return slot, err
+	}
+	if slotStarted(slot, date, now) {
+		return slot, errDeliverySlotStarted
+	}
+
+	bySlot, total, err := deliveryBookings(tx.Clauses(clause.Locking{Strength: "SHARE"}), merchantID, date)
+	if err != nil {
+		return slot, err
+	}
+	if slot.Capacity > 0 && bySlot[slot.ID] >= int64(slot.Capacity) {
+		return slot, errDeliverySlotFull
+	}
+	if setting.DailyCapacity > 0 && total >= int64(setting.DailyCapacity) {
+		return slot, errDeliveryDayFull
+	}
+	return slot, nil
+}
+
+// slotAvailability 某天某个时段的预约情况
+type slotAvailability struct {
+	models.DeliverySlot
+	Label     string `json:"label"`
+	Booked    int64  `json:"booked"`
+	Remaining int64  `json:"remaining"` // -1 表示不限
+	Available bool   `json:"available"`
+}
+
+// 查询商家某天可预约的配送时段，供下单页选择
+func deliverySlotsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var merchant models.Merchant
+		if err := db.Where("id = ? AND status = ?", c.Query("merchant_id"), models.MerchantStatusNormal).First(&merchant).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "商家不存在", nil)
+			return
+		}
+		setting, err := loadDeliverySetting(db, merchant.ID)
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取配送设置失败", nil)
+			return
+		}
+
+		now := time.Now()
+		date := c.DefaultQuery("date", now.Format(deliveryDateLayout))
+		dateErr := checkDeliveryDate(db, setting, date, now)
+		if dateErr == errDeliveryDateInvalid {
+			jsonResponse(c, http.StatusBadRequest, dateErr.Error(), nil)
+			return
+		} else if dateErr != nil && !isDeliveryError(dateErr) {
+			jsonResponse(c, http.StatusInternalServerError, "获取配送时段失败", nil)
+			return
+		}
+
+		var slots []models.DeliverySlot
+		if err := db.Where("merchant_id = ? AND enabled = ?", merchant.ID, true).Order("sort, start_time").Find(&slots).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取配送时段失败", nil)
+			return
+		}
+		bySlot, total, err := deliveryBookings(db, merchant.ID, date)
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取配送时段失败", nil)
+			return
+		}
+		dayFull := setting.DailyCapacity > 0 && total >= int64(setting.DailyCapacity)
+
+		list := make([]slotAvailability, 0, len(slots))
+		for _, s := range slots {
+			item := slotAvailability{DeliverySlot: s, Label: s.Label(), Booked: bySlot[s.ID], Remaining: -1}
+			if s.Capacity > 0 {
+				item.Remaining = int64(s.Capacity) - item.Booked
+				if item.Remaining < 0 {
+					item.Remaining = 0
+				}
+			}
+			item.Available = dateErr == nil && !dayFull && item.Remaining != 0 && !slotStarted(s, date, now)
+			list = append(list, item)
+		}
+
+		reason := ""
+		if dateErr != nil {
+			reason = dateErr.Error()
+		} else if dayFull {
+			reason = errDeliveryDayFull.Error()
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", gin.H{
+			"date":             date,
+			"available":        reason == "",
+			"reason":           reason,
+			"same_day_cutoff":  setting.SameDayCutoff,
+			"max_advance_days": setting.MaxAdvanceDays,
+			"slots":            list,
+		})
+	}
+}
+
+// 商家获取配送设置
+func merchantGetDeliverySettingHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setting, err := loadDeliverySetting(db, c.MustGet("merchantID").(uint))
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取配送设置失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", setting)
+	}
+}
+
+// 商家修改配送设置
+func merchantUpdateDeliverySettingHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			DailyCapacity  int    `json:"daily_capacity" binding:"min=0"`
+			SameDayCutoff  string `json:"same_day_cutoff"`
+			MaxAdvanceDays int    `json:"max_advance_days" binding:"min=0,max=365"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+		if req.SameDayCutoff != "" && !validClock(req.SameDayCutoff) {
+			jsonResponse(c, http.StatusBadRequest, "截单时间格式应为 HH:MM", nil)
+			return
+		}
+
+		merchantID := c.MustGet("merchantID").(uint)
+		setting, err := loadDeliverySetting(db, merchantID)
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "保存配送设置失败", nil)
+			return
+		}
+		setting.DailyCapacity = req.DailyCapacity
+		setting.SameDayCutoff = req.SameDayCutoff
+		setting.MaxAdvanceDays = req.MaxAdvanceDays
+		if err := db.Save(&setting).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "保存配送设置失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "保存成功", setting)
+	}
+}
+
+// deliverySlotRequest 新增、修改配送时段的参数
+type deliverySlotRequest struct {
+	StartTime string `json:"start_time" binding:"required"`
+	EndTime   string `json:"end_time" binding:"required"`
+	Capacity  int    `json:"capacity" binding:"min=0"`
+	Enabled   *bool  `json:"enabled"`
+	Sort      int    `json:"sort"`
+}
+
+// bindDeliverySlot 解析并校验时段参数
+func bindDeliverySlot(c *gin.Context) (deliverySlotRequest, bool) {
+	var req deliverySlotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+		return req, false
+	}
+	if !validClock(req.StartTime) || !validClock(req.EndTime) {
+		jsonResponse(c, http.StatusBadRequest, "时间格式应为 HH:MM", nil)
+		return req, false
+	}
+	if req.StartTime >= req.EndTime {
+		jsonResponse(c, http.StatusBadRequest, "结束时间必须晚于开始时间", nil)
+		return req, false
+	}
+	return req, true
+}
+
+// 商家配送时段列表
+func merchantListDeliverySlotsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var slots []models.DeliverySlot
+		if err := db.Where("merchant_id = ?", c.MustGet("merchantID").(uint)).Order("sort, start_time").Find(&slots).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取配送时段失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", slots)
+	}
+}
+
+// 商家新增配送时段
+func merchantCreateDeliverySlotHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, ok := bindDeliverySlot(c)
+		if !ok {
+			return
+		}
+		slot := models.DeliverySlot{
+			MerchantID: c.MustGet("merchantID").(uint),
+			StartTime:  req.StartTime,
+			EndTime:    req.EndTime,
+			Capacity:   req.Capacity,
+			Enabled:    req.Enabled == nil || *req.Enabled,
+			Sort:       req.Sort,
+		}
+		if err := db.Create(&slot).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "新增配送时段失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusCreated, "新增成功", slot)
+	}
+}
+
+// 商家修改配送时段，已下单的订单保留下单时的时段文字
+func merchantUpdateDeliverySlotHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var slot models.DeliverySlot
+		if err := db.Where("id = ? AND merchant_id = ?", c.Param("id"), c.MustGet("merchantID").(uint)).First(&slot).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "配送时段不存在", nil)
+			return
+		}
+		req, ok := bindDeliverySlot(c)
+		if !ok {
+			return
+		}
+		slot.StartTime = req.StartTime
+		slot.EndTime = req.EndTime
+		slot.Capacity = req.Capacity
+		slot.Sort = req.Sort
+		if req.Enabled != nil {
+			slot.Enabled = *req.Enabled
+		}
+		if err := db.Save(&slot).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "修改配送时段失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "修改成功", slot)
+	}
+}
+
+// 商家删除配送时段
+func merchantDeleteDeliverySlotHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Where("id = ? AND merchant_id = ?", c.Param("id"), c.MustGet("merchantID").(uint)).Delete(&models.DeliverySlot{})
+		if result.Error != nil {
+			jsonResponse(c, http.StatusInternalServerError, "删除配送时段失败", nil)
+			return
+		}
+		if result.RowsAffected == 0 {
+			jsonResponse(c, http.StatusNotFound, "配送时段不存在", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "删除成功", nil)
+	}
+}
+
+// 商家停配日期列表，默认只返回今天及以后的
+func merchantListBlackoutsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Where("merchant_id = ?", c.MustGet("merchantID").(uint))
+		if c.Query("all") != "1" {
+			query = query.Where("date >= ?", time.Now().Format(deliveryDateLayout))
+		}
+		var blackouts []models.DeliveryBlackout
+		if err := query.Order("date").Find(&blackouts).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取停配日期失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", blackouts)
+	}
+}
+
+// 商家新增停配日期，已有的日期只更新原因
+func merchantCreateBlackoutHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Date   string `json:"date" binding:"required"`
+			Reason string `json:"reason" binding:"max=100"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+		if _, err := time.Parse(deliveryDateLayout, req.Date); err != nil {
+			jsonResponse(c, http.StatusBadRequest, errDeliveryDateInvalid.Error(), nil)
+			return
+		}
+
+		blackout := models.DeliveryBlackout{MerchantID: c.MustGet("merchantID").(uint), Date: req.Date}
+		err := db.Where(&blackout).Assign(models.DeliveryBlackout{Reason: req.Reason}).FirstOrCreate(&blackout).Error
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "新增停配日期失败", nil)
+			return
+		}
+		jsonResponse(c, http.StatusCreated, "新增成功", blackout)
+	}
+}
+
+// 商家删除停配日期
+func merchantDeleteBlackoutHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := db.Where("id = ? AND merchant_id = ?", c.Param("id"), c.MustGet("merchantID").(uint)).Delete(&models.DeliveryBlackout{})
+		if result.Error != nil {
+			jsonResponse(c, http.StatusInternalServerError, "删除停配日期失败", nil)
+			return
+		}
+		if result.RowsAffected == 0 {
+			jsonResponse(c, http.StatusNotFound, "停配日期不存在", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "删除成功", nil)
+	}
+}
+
+// scheduleGroup 配送单中一个时段的订单
+type scheduleGroup struct {
+	SlotID   uint           `json:"slot_id"`
+	Label    string         `json:"label"`
+	Capacity int            `json:"capacity"`
+	Orders   []models.Order `json:"orders"`
+}
+
+// 商家某天的配送单，按时段分组，只包含已支付待配送和已完成的订单
+func merchantDeliveryScheduleHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		merchantID := c.MustGet("merchantID").(uint)
+		date := c.DefaultQuery("date", time.Now().Format(deliveryDateLayout))
+		if _, err := time.Parse(deliveryDateLayout, date); err != nil {
+			jsonResponse(c, http.StatusBadRequest, errDeliveryDateInvalid.Error(), nil)
+			return
+		}
+
+		var slots []models.DeliverySlot
+		if err := db.Where("merchant_id = ?", merchantID).Order("sort, start_time").Find(&slots).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取配送单失败", nil)
+			return
+		}
+		var orders []models.Order
+		err := db.Preload("Items").
+			Where("merchant_id = ? AND delivery_date = ? AND status IN ?", merchantID, date, []string{
+				models.OrderStatusPaid, models.OrderStatusPreparing, models.OrderStatusDelivering, models.OrderStatusCompleted,
+			}).
+			Order("delivery_slot, id").
+			Find(&orders).Error
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "获取配送单失败", nil)
+			return
+		}
+
+		groups := make([]*scheduleGroup, 0, len(slots))
+		index := make(map[uint]*scheduleGroup, len(slots))
+		for _, s := range slots {
+			g := &scheduleGroup{SlotID: s.ID, Label: s.Label(), Capacity: s.Capacity, Orders: []models.Order{}}
+			groups = append(groups, g)
+			index[s.ID] = g
+		}
+		// 时段被删除后，原有订单按下单时的时段文字单独分组
+		for _, o := range orders {
+			g, ok := index[o.DeliverySlotID]
+			if !ok {
+				g = &scheduleGroup{SlotID: o.DeliverySlotID, Label: o.DeliverySlot, Orders: []models.Order{}}
+				groups = append(groups, g)
+				index[o.DeliverySlotID] = g
+			}
+			g.Orders = append(g.Orders, o)
+		}
+
+		jsonResponse(c, http.StatusOK, "获取成功", gin.H{
+			"date":   date,
+			"total":  len(orders),
+			"groups": groups,
+		})
+	}
+}
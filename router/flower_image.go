@@ -0,0 +1,387 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --------------------------------------商家端：鲜花图片管理
+
+var (
+	errFlowerNotFound      = errors.New("鲜花不存在或无权访问")
+	errFlowerImageNotFound = errors.New("图片不存在")
+	errFlowerImageEmpty    = errors.New("请选择要上传的图片")
+	errFlowerImageLast     = errors.New("至少保留一张图片")
+	errFlowerImageOrder    = errors.New("排序列表必须包含该鲜花的全部图片")
+)
+
+// flowerImageErrorStatus 图片管理错误对应的 HTTP 状态码
+func flowerImageErrorStatus(err error) int {
+	switch err {
+	case errFlowerNotFound, errFlowerImageNotFound:
+		return http.StatusNotFound
+	case errFlowerImageEmpty, errFlowerImageLast, errFlowerImageOrder:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondFlowerImageError 返回图片管理相关错误，上传校验错误交给 respondUploadError，未知错误不暴露细节
+func respondFlowerImageError(c *gin.Context, err error, fallback string) {
+	if uploadErrorStatus(err) != http.StatusInternalServerError {
+		respondUploadError(c, err)
+		return
+	}
+	status := flowerImageErrorStatus(err)
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		msg = fallback
+	}
+	jsonResponse(c, status, msg, nil)
+}
+
+// orderedImages 鲜花图片按展示顺序排列，用于 Preload
+func orderedImages(db *gorm.DB) *gorm.DB {
+	return db.Order("sort, id")
+}
+
+// findMerchantFlower 查询商家自己的鲜花及其图片、规格
+func findMerchantFlower(db *gorm.DB, merchantID uint, flowerID interface{}) (models.Flower, error) {
+	var flower models.Flower
+	err := db.Scopes(preloadVariants).Preload("Images", orderedImages).Where("id = ? AND merchant_id = ?", flowerID, merchantID).First(&flower).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return flower, errFlowerNotFound
+	}
+	return flower, err
+}
+
+// lockMerchantFlower 在事务中锁住商家的鲜花行后再读取，修改规格和价格、库存的请求对同一鲜花依次执行，
+// 读到的规格在事务提交前不会被其他请求修改
+func lockMerchantFlower(tx *gorm.DB, merchantID uint, flowerID interface{}) (models.Flower, error) {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		Where("id = ? AND merchant_id = ?", flowerID, merchantID).First(&models.Flower{}).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Flower{}, errFlowerNotFound
+	}
+	if err != nil {
+		return models.Flower{}, err
+	}
+	return findMerchantFlower(tx, merchantID, flowerID)
+}
+
+// idParam 路由中的ID，格式不对时为 0，查询时视为不存在
+func idParam(c *gin.Context, name string) uint {
+	id, _ := strconv.ParseUint(c.Param(name), 10, 64)
+	return uint(id)
+}
+
+// saveImageOrder 按 ids 的顺序重写图片的 sort
+func saveImageOrder(tx *gorm.DB, ids []uint) error {
+	for i, id := range ids {
+		if err := tx.Model(&models.FlowerImage{}).Where("id = ?", id).UpdateColumn("sort", i).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// respondFlowerImages 返回最新的图片列表；商品目录已在修改图片的事务中同步
+func respondFlowerImages(c *gin.Context, db *gorm.DB, store storage.Storage, merchantID, flowerID uint, msg string) {
+	flower, err := findMerchantFlower(db, merchantID, flowerID)
+	if err != nil {
+		respondFlowerImageError(c, err, "获取图片失败")
+		return
+	}
+	resolveFlowerImages(store, &flower)
+	jsonResponse(c, http.StatusOK, msg, flower.Images)
+}
+
+// 追加图片，排在已有图片之后
+func merchantAddFlowerImagesHandler(db *gorm.DB, store storage.Storage, limits uploadLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		merchantID := c.MustGet("merchantID").(uint)
+		images, err := parseImageUploads(c, limits)
+		if err == nil && len(images) == 0 {
+			err = errFlowerImageEmpty
+		}
+		if err != nil {
+			respondFlowerImageError(c, err, "上传图片失败")
+			return
+		}
+		flower, err := findMerchantFlower(db, merchantID, c.Param("id"))
+		if err != nil {
+			respondFlowerImageError(c, err, "上传图片失败")
+			return
+		}
+		if len(flower.Images)+len(images) > limits.MaxCount {
+			respondFlowerImageError(c, errUploadTooMany, "上传图片失败")
+			return
+		}
+
+		saved, err := stageImages(c, store, images, 0)
+		if err != nil {
+			log.Printf("save image for flower %d failed: %v", flower.ID, err)
+			jsonResponse(c, http.StatusInternalServerError, "保存图片失败", nil)
+			return
+		}
+		// 锁住鲜花行后重新统计图片数量，并发上传时不会超过数量上限
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+				Where("id = ? AND merchant_id = ?", flower.ID, merchantID).First(&models.Flower{}).Error; err != nil {
+				return err
+			}
+			var stats struct {
+				Count   int
+				MaxSort int
+			}
+			if err := tx.Model(&models.FlowerImage{}).Select("COUNT(*) AS count, COALESCE(MAX(sort), -1) AS max_sort").
+				Where("flower_id = ?", flower.ID).Scan(&stats).Error; err != nil {
+				return err
+			}
+			if stats.Count+len(saved) > limits.MaxCount {
+				return errUploadTooMany
+			}
+			for i := range saved {
+				saved[i].FlowerID = flower.ID
+				saved[i].Sort = stats.MaxSort + 1 + i
+			}
+			if err := tx.Create(&saved).Error; err != nil {
+				return err
+			}
+			return syncFlowerCatalog(tx, store, flower.ID)
+		})
+		if err != nil {
+			discardImages(store, saved)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = errFlowerNotFound
+			}
+			respondFlowerImageError(c, err, "保存图片失败")
+			return
+		}
+		respondFlowerImages(c, db, store, merchantID, flower.ID, "上传成功")
+	}
+}
+
+// 删除一张图片，同时删除存储中的文件
+func merchantDeleteFlowerImageHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		merchantID := c.MustGet("merchantID").(uint)
+		var flower models.Flower
+		var removed models.FlowerImage
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if flower, err = findMerchantFlower(tx, merchantID, c.Param("id")); err != nil {
+				return err
+			}
+			imageID := idParam(c, "image_id")
+			for _, img := range flower.Images {
+				if img.ID == imageID {
+					removed = img
+				}
+			}
+			if removed.ID == 0 {
+				return errFlowerImageNotFound
+			}
+			if len(flower.Images) == 1 {
+				return errFlowerImageLast
+			}
+			// 使用该图片的 SKU 改为使用封面
+			if err := tx.Model(&models.FlowerSKU{}).Where("flower_id = ? AND image_id = ?", flower.ID, removed.ID).
+				UpdateColumn("image_id", 0).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&removed).Error; err != nil {
+				return err
+			}
+			return syncFlowerCatalog(tx, store, flower.ID)
+		})
+		if err != nil {
+			respondFlowerImageError(c, err, "删除图片失败")
+			return
+		}
+		discardImages(store, []models.FlowerImage{removed})
+		respondFlowerImages(c, db, store, merchantID, flower.ID, "删除成功")
+	}
+}
+
+// 调整图片顺序，ids 必须包含该鲜花的全部图片，第一张为封面
+func merchantReorderFlowerImagesHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		merchantID := c.MustGet("merchantID").(uint)
+		var req struct {
+			IDs []uint `json:"ids" binding:"required,min=1"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		var flower models.Flower
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if flower, err = findMerchantFlower(tx, merchantID, c.Param("id")); err != nil {
+				return err
+			}
+			if len(req.IDs) != len(flower.Images) {
+				return errFlowerImageOrder
+			}
+			seen := make(map[uint]bool, len(flower.Images))
+			for _, img := range flower.Images {
+				seen[img.ID] = true
+			}
+			for _, id := range req.IDs {
+				if !seen[id] {
+					return errFlowerImageOrder
+				}
+				delete(seen, id)
+			}
+			if err := saveImageOrder(tx, req.IDs); err != nil {
+				return err
+			}
+			return syncFlowerCatalog(tx, store, flower.ID)
+		})
+		if err != nil {
+			respondFlowerImageError(c, err, "调整顺序失败")
+			return
+		}
+		respondFlowerImages(c, db, store, merchantID, flower.ID, "排序已更新")
+	}
+}
+
+// 设置封面，该图片移到第一位，其余图片保持原有顺序
+func merchantSetFlowerCoverHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		merchantID := c.MustGet("merchantID").(uint)
+		var flower models.Flower
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if flower, err = findMerchantFlower(tx, merchantID, c.Param("id")); err != nil {
+				return err
+			}
+			var cover uint
+			imageID := idParam(c, "image_id")
+			rest := make([]uint, 0, len(flower.Images))
+			for _, img := range flower.Images {
+				if img.ID == imageID {
+					cover = img.ID
+					continue
+				}
+				rest = append(rest, img.ID)
+			}
+			if cover == 0 {
+				return errFlowerImageNotFound
+			}
+			if err := saveImageOrder(tx, append([]uint{cover}, rest...)); err != nil {
+				return err
+			}
+			return syncFlowerCatalog(tx, store, flower.ID)
+		})
+		if err != nil {
+			respondFlowerImageError(c, err, "设置封面失败")
+			return
+		}
+		respondFlowerImages(c, db, store, merchantID, flower.ID, "封面已更新")
+	}
+}
+
+// --------------------------------------孤立图片清理
+
+// orphanImageGrace 文件写入后超过该时间仍没有图片记录引用才会被清理，
+// 避免删除正在上传、尚未写入数据库的文件
+const orphanImageGrace = 24 * time.Hour
+
+// StartImageSweeper 定时删除存储中没有图片记录引用的文件
+func StartImageSweeper(db *gorm.DB, store storage.Storage, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if n, err := sweepOrphanImages(context.Background(), db, store, time.Now().Add(-orphanImageGrace)); err != nil {
+				log.Printf("sweep orphan images failed: %v", err)
+			} else if n > 0 {
+				log.Printf("deleted %d orphan image files", n)
+			}
+		}
+	}()
+}
+
+// orphanImageBatch 每次到数据库核对引用的 key 数量
+const orphanImageBatch = 500
+
+// sweepOrphanImages 删除 before 之前写入且没有被任何图片记录(包括已删除鲜花的图片)引用的文件，返回删除的数量；
+// 只检查 flowers/ 目录和迁移 3 之前上传到存储根目录的文件，其他目录中的对象不属于鲜花图片，不会被删除
+func sweepOrphanImages(ctx context.Context, db *gorm.DB, store storage.Storage, before time.Time) (int, error) {
+	var orphans, batch []string
+	check := func() error {
+		referenced, err := referencedImageKeys(db, batch)
+		if err != nil {
+			return err
+		}
+		for _, key := range batch {
+			if !referenced[key] {
+				orphans = append(orphans, key)
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+	err := store.List(ctx, "", func(obj storage.Object) error {
+		if !strings.HasPrefix(obj.Key, flowerImagePrefix) && strings.Contains(obj.Key, "/") {
+			return nil
+		}
+		if !obj.ModTime.Before(before) {
+			return nil
+		}
+		batch = append(batch, obj.Key)
+		if len(batch) < orphanImageBatch {
+			return nil
+		}
+		return check()
+	})
+	if err == nil && len(batch) > 0 {
+		err = check()
+	}
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, key := range orphans {
+		if err := store.Delete(ctx, key); err != nil {
+			log.Printf("delete orphan image %s failed: %v", key, err)
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// referencedImageKeys 返回 keys 中被图片记录的原图或任一尺寸引用的 key
+func referencedImageKeys(db *gorm.DB, keys []string) (map[string]bool, error) {
+	var images []models.FlowerImage
+	err := db.Unscoped().Select("path", "big_path", "mid_path", "sma_path", "big_webp_path", "mid_webp_path", "sma_webp_path").
+		Where("path IN ? OR big_path IN ? OR mid_path IN ? OR sma_path IN ? OR big_webp_path IN ? OR mid_webp_path IN ? OR sma_webp_path IN ?",
+			keys, keys, keys, keys, keys, keys, keys).
+		Find(&images).Error
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool, len(images)*7)
+	for _, img := range images {
+		for _, key := range img.Keys() {
+			referenced[key] = true
+		}
+	}
+	return referenced, nil
+}
@@ -0,0 +1,203 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------------------------------------商家端：部分更新鲜花
+
+// maxFlowerPrice 价格上限，对应 decimal(10,2)
+const maxFlowerPrice = 99999999.99
+
+// flowerPatch 部分更新鲜花的请求，为 nil 的字段表示请求中没有出现，不修改
+type flowerPatch struct {
+	Name        *string
+	Price       *float64
+	Stock       *int
+	CategoryID  *int
+	Description *string
+	Status      *int
+}
+
+// errFieldsInvalid 事务中的字段校验失败，逐个字段的错误由调用方另行返回
+var errFieldsInvalid = errors.New("参数校验失败")
+
+// fieldError 单个字段的校验错误，Field 为请求中的字段名
+type fieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+// fields 请求中的字段名及解析目标
+func (p *flowerPatch) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"name":        &p.Name,
+		"price":       &p.Price,
+		"stock":       &p.Stock,
+		"category_id": &p.CategoryID,
+		"description": &p.Description,
+		"status":      &p.Status,
+	}
+}
+
+// decodeFlowerPatch 逐个字段解析请求，类型不对、值为 null 和不支持的字段都作为字段错误返回；
+// 请求体不是 JSON 对象时 ok 为 false
+func decodeFlowerPatch(body []byte) (patch flowerPatch, failed []fieldError, ok bool) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil || raw == nil {
+		return patch, nil, false
+	}
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	targets := patch.fields()
+	for _, key := range keys {
+		target, known := targets[key]
+		switch {
+		case !known:
+			failed = append(failed, fieldError{key, "不支持修改该字段"})
+		case bytes.Equal(bytes.TrimSpace(raw[key]), []byte("null")):
+			failed = append(failed, fieldError{key, "不能为 null"})
+		default:
+			if err := json.Unmarshal(raw[key], target); err != nil {
+				failed = append(failed, fieldError{key, "类型错误"})
+			}
+		}
+	}
+	return patch, failed, true
+}
+
+// validate 校验出现且解析成功的字段，分类需存在且未删除
+func (p *flowerPatch) validate(db *gorm.DB) ([]fieldError, error) {
+	var failed []fieldError
+	if p.Name != nil {
+		*p.Name = strings.TrimSpace(*p.Name)
+		if *p.Name == "" {
+			failed = append(failed, fieldError{"name", "名称不能为空"})
+		} else if utf8.RuneCountInString(*p.Name) > 100 {
+			failed = append(failed, fieldError{"name", "名称不能超过 100 个字"})
+		}
+	}
+	if p.Price != nil && (*p.Price <= 0 || *p.Price > maxFlowerPrice) {
+		failed = append(failed, fieldError{"price", "价格必须大于 0 且不超过 99999999.99"})
+	}
+	if p.Stock != nil && *p.Stock < 0 {
+		failed = append(failed, fieldError{"stock", "库存不能小于 0"})
+	}
+	if p.Status != nil && *p.Status != 0 && *p.Status != 1 {
+		failed = append(failed, fieldError{"status", "状态只能为 0(下架) 或 1(上架)"})
+	}
+	if p.CategoryID != nil {
+		if _, err := findCategory(db, *p.CategoryID); err == errCategoryNotFound {
+			failed = append(failed, fieldError{"category_id", "分类不存在"})
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return failed, nil
+}
+
+// updates 需要写入数据库的列
+func (p *flowerPatch) updates() map[string]interface{} {
+	updates := map[string]interface{}{}
+	if p.Name != nil {
+		updates["name"] = *p.Name
+	}
+	if p.Price != nil {
+		updates["price"] = *p.Price
+	}
+	if p.Stock != nil {
+		updates["stock"] = *p.Stock
+	}
+	if p.CategoryID != nil {
+		updates["category_id"] = *p.CategoryID
+	}
+	if p.Description != nil {
+		updates["description"] = *p.Description
+	}
+	if p.Status != nil {
+		updates["status"] = *p.Status
+	}
+	return updates
+}
+
+// 商家部分更新鲜花，只修改请求中出现的字段，校验失败时逐个字段的错误放在 message.errors 中
+func merchantPatchFlowerHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		merchantID := c.MustGet("merchantID").(uint)
+		body, err := c.GetRawData()
+		if err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+		patch, failed, ok := decodeFlowerPatch(body)
+		if !ok {
+			jsonResponse(c, http.StatusBadRequest, "请求体必须是 JSON 对象", nil)
+			return
+		}
+
+		// 锁住鲜花行后再检查是否有规格并写入，并发设置规格时不会把价格和库存写到已有规格的鲜花上
+		var flower models.Flower
+		updates := patch.updates()
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if flower, err = lockMerchantFlower(tx, merchantID, c.Param("id")); err != nil {
+				return err
+			}
+			invalid, err := patch.validate(tx)
+			if err != nil {
+				return err
+			}
+			failed = append(failed, invalid...)
+			// 有规格的鲜花价格和库存由 SKU 决定
+			if len(flower.SKUs) > 0 {
+				if patch.Price != nil {
+					failed = append(failed, fieldError{"price", "该鲜花已设置规格，请修改规格的价格"})
+				}
+				if patch.Stock != nil {
+					failed = append(failed, fieldError{"stock", "该鲜花已设置规格，请修改规格的库存"})
+				}
+			}
+			if len(failed) > 0 {
+				return errFieldsInvalid
+			}
+			if len(updates) == 0 {
+				return nil
+			}
+			if err := tx.Model(&models.Flower{}).Where("id = ?", flower.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+			return syncFlowerCatalog(tx, store, flower.ID)
+		})
+		if err == errFieldsInvalid {
+			jsonResponse(c, http.StatusBadRequest, "参数校验失败", gin.H{"errors": failed})
+			return
+		}
+		if err != nil {
+			respondFlowerImageError(c, err, "更新鲜花失败")
+			return
+		}
+		if len(updates) > 0 {
+			if flower, err = findMerchantFlower(db, merchantID, flower.ID); err != nil {
+				jsonResponse(c, http.StatusInternalServerError, "更新鲜花失败", nil)
+				return
+			}
+		}
+		resolveFlowerImages(store, &flower)
+		jsonResponse(c, http.StatusOK, "鲜花更新成功", flower)
+	}
+}
@@ -0,0 +1,384 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------------------------------------商家端：鲜花规格(SKU)
+// 鲜花可以按 支数、包装 等维度设置规格，每个规格组合是一个 SKU，有自己的价格、库存和图片；
+// 有 SKU 的鲜花由 SKU 决定价格和库存，鲜花的 price 为最低价、stock 为库存之和，用于列表和商品目录
+
+// 规格数量限制
+const (
+	maxFlowerOptions     = 3   // 规格维度
+	maxFlowerOptionItems = 20  // 每个维度的规格值
+	maxFlowerSKUs        = 200 // SKU
+)
+
+var (
+	errFlowerSKURequired = errors.New("请选择商品规格")
+	errFlowerSKUNotFound = errors.New("规格不存在")
+)
+
+// preloadVariants 查询鲜花时一起加载规格维度和 SKU
+func preloadVariants(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Options", func(db *gorm.DB) *gorm.DB { return db.Order("sort, id") }).
+		Preload("SKUs", func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+}
+
+// findFlowerSKU 查询 SKU，只返回属于该鲜花的
+func findFlowerSKU(db *gorm.DB, flowerID, skuID uint) (models.FlowerSKU, error) {
+	var sku models.FlowerSKU
+	err := db.Where("id = ? AND flower_id = ?", skuID, flowerID).First(&sku).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sku, errFlowerSKUNotFound
+	}
+	return sku, err
+}
+
+// flowerHasSKUs 鲜花是否设置了规格
+func flowerHasSKUs(db *gorm.DB, flowerID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.FlowerSKU{}).Where("flower_id = ?", flowerID).Count(&count).Error
+	return count > 0, err
+}
+
+// syncFlowerSKUTotals SKU 的价格或库存变化后，把最低价和库存之和写回鲜花并更新商品目录的库存；
+// 没有 SKU 的鲜花不变
+func syncFlowerSKUTotals(tx *gorm.DB, flowerID uint) error {
+	hasSKUs, err := flowerHasSKUs(tx, flowerID)
+	if err != nil || !hasSKUs {
+		return err
+	}
+	err = tx.Model(&models.Flower{}).Where("id = ?", flowerID).UpdateColumns(map[string]interface{}{
+		"price": gorm.Expr("(SELECT MIN(price) FROM flower_skus WHERE flower_id = ?)", flowerID),
+		"stock": gorm.Expr("(SELECT SUM(stock) FROM flower_skus WHERE flower_id = ?)", flowerID),
+	}).Error
+	if err != nil {
+		return err
+	}
+	return syncCatalogStock(tx, flowerID)
+}
+
+// flowerOptionInput 规格维度
+type flowerOptionInput struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// flowerSKUInput 规格组合，Values 按维度顺序填写
+type flowerSKUInput struct {
+	Values  []string `json:"values"`
+	Price   float64  `json:"price"`
+	Stock   int      `json:"stock"`
+	ImageID uint     `json:"image_id"`
+}
+
+// flowerVariantsInput 整体设置鲜花规格的请求
+type flowerVariantsInput struct {
+	Options []flowerOptionInput `json:"options"`
+	SKUs    []flowerSKUInput    `json:"skus"`
+}
+
+// checkSKUName 校验规格名称和规格值：不能为空、不超过 20 个字、不能包含分隔符
+func checkSKUName(name string) string {
+	switch {
+	case name == "":
+		return "不能为空"
+	case utf8.RuneCountInString(name) > 20:
+		return "不能超过 20 个字"
+	case strings.Contains(name, models.SKUSpecSeparator):
+		return "不能包含 " + models.SKUSpecSeparator
+	}
+	return ""
+}
+
+// checkSKUPrice 校验 SKU 的价格、库存和图片，field 为字段名前缀
+func checkSKUPrice(flower models.Flower, field string, price *float64, stock *int, imageID *uint) []fieldError {
+	var failed []fieldError
+	if price != nil && (*price <= 0 || *price > maxFlowerPrice) {
+		failed = append(failed, fieldError{field + "price", "价格必须大于 0 且不超过 99999999.99"})
+	}
+	if stock != nil && *stock < 0 {
+		failed = append(failed, fieldError{field + "stock", "库存不能小于 0"})
+	}
+	if imageID != nil && *imageID != 0 {
+		found := false
+		for _, img := range flower.Images {
+			found = found || img.ID == *imageID
+		}
+		if !found {
+			failed = append(failed, fieldError{field + "image_id", "图片不存在"})
+		}
+	}
+	return failed
+}
+
+// validate 校验规格维度和 SKU，规格名称前后的空格会被去掉
+func (in *flowerVariantsInput) validate(flower models.Flower) []fieldError {
+	var failed []fieldError
+	if len(in.Options) == 0 || len(in.Options) > maxFlowerOptions {
+		failed = append(failed, fieldError{"options", fmt.Sprintf("规格维度为 1 到 %d 个", maxFlowerOptions)})
+	}
+	optionNames := map[string]bool{}
+	optionValues := make([]map[string]bool, len(in.Options))
+	for i := range in.Options {
+		opt := &in.Options[i]
+		field := fmt.Sprintf("options[%d].", i)
+		opt.Name = strings.TrimSpace(opt.Name)
+		if msg := checkSKUName(opt.Name); msg != "" {
+			failed = append(failed, fieldError{field + "name", msg})
+		} else if optionNames[opt.Name] {
+			failed = append(failed, fieldError{field + "name", "规格名称重复"})
+		}
+		optionNames[opt.Name] = true
+
+		if len(opt.Values) == 0 || len(opt.Values) > maxFlowerOptionItems {
+			failed = append(failed, fieldError{field + "values", fmt.Sprintf("规格值为 1 到 %d 个", maxFlowerOptionItems)})
+		}
+		optionValues[i] = map[string]bool{}
+		for j := range opt.Values {
+			opt.Values[j] = strings.TrimSpace(opt.Values[j])
+			value := opt.Values[j]
+			if msg := checkSKUName(value); msg != "" {
+				failed = append(failed, fieldError{fmt.Sprintf("%svalues[%d]", field, j), msg})
+			} else if optionValues[i][value] {
+				failed = append(failed, fieldError{fmt.Sprintf("%svalues[%d]", field, j), "规格值重复"})
+			}
+			optionValues[i][value] = true
+		}
+	}
+
+	if len(in.SKUs) == 0 || len(in.SKUs) > maxFlowerSKUs {
+		failed = append(failed, fieldError{"skus", fmt.Sprintf("SKU 为 1 到 %d 个", maxFlowerSKUs)})
+	}
+	specs := map[string]bool{}
+	for i := range in.SKUs {
+		sku := &in.SKUs[i]
+		field := fmt.Sprintf("skus[%d].", i)
+		if len(sku.Values) != len(in.Options) {
+			failed = append(failed, fieldError{field + "values", "规格值数量与规格维度不一致"})
+		} else {
+			valid := true
+			for j := range sku.Values {
+				sku.Values[j] = strings.TrimSpace(sku.Values[j])
+				if !optionValues[j][sku.Values[j]] {
+					failed = append(failed, fieldError{fmt.Sprintf("%svalues[%d]", field, j), "不是该维度的规格值"})
+					valid = false
+				}
+			}
+			spec := models.SKUSpec(sku.Values)
+			if valid && specs[spec] {
+				failed = append(failed, fieldError{field + "values", "规格组合重复"})
+			}
+			specs[spec] = true
+		}
+		failed = append(failed, checkSKUPrice(flower, field, &sku.Price, &sku.Stock, &sku.ImageID)...)
+	}
+	return failed
+}
+
+// saveFlowerVariants 在事务中替换鲜花的规格维度和 SKU，规格组合不变的 SKU 保留原 ID，
+// 已加入购物车的该规格不受影响；不再出现的 SKU 被删除
+func saveFlowerVariants(tx *gorm.DB, flowerID uint, in flowerVariantsInput) error {
+	if err := tx.Where("flower_id = ?", flowerID).Delete(&models.FlowerOption{}).Error; err != nil {
+		return err
+	}
+	for i, opt := range in.Options {
+		option := models.FlowerOption{FlowerID: flowerID, Name: opt.Name, Values: opt.Values, Sort: i}
+		if err := tx.Create(&option).Error; err != nil {
+			return err
+		}
+	}
+
+	var existing []models.FlowerSKU
+	if err := tx.Where("flower_id = ?", flowerID).Find(&existing).Error; err != nil {
+		return err
+	}
+	bySpec := make(map[string]models.FlowerSKU, len(existing))
+	for _, sku := range existing {
+		bySpec[sku.Spec] = sku
+	}
+	keep := make([]uint, 0, len(in.SKUs))
+	for _, input := range in.SKUs {
+		sku := bySpec[models.SKUSpec(input.Values)]
+		sku.FlowerID = flowerID
+		sku.Spec = models.SKUSpec(input.Values)
+		sku.Values = input.Values
+		sku.Price = input.Price
+		sku.Stock = input.Stock
+		sku.ImageID = input.ImageID
+		if err := tx.Save(&sku).Error; err != nil {
+			return err
+		}
+		keep = append(keep, sku.ID)
+	}
+	if err := tx.Where("flower_id = ? AND id NOT IN ?", flowerID, keep).Delete(&models.FlowerSKU{}).Error; err != nil {
+		return err
+	}
+	return syncFlowerSKUTotals(tx, flowerID)
+}
+
+// respondFlowerVariants 返回最新的规格；商品目录已在修改规格的事务中同步
+func respondFlowerVariants(c *gin.Context, db *gorm.DB, store storage.Storage, merchantID, flowerID uint, msg string) {
+	flower, err := findMerchantFlower(db, merchantID, flowerID)
+	if err != nil {
+		respondFlowerSKUError(c, err, "获取规格失败")
+		return
+	}
+	resolveFlowerImages(store, &flower)
+	jsonResponse(c, http.StatusOK, msg, gin.H{"options": flower.Options, "skus": flower.SKUs})
+}
+
+// respondFlowerSKUError 返回规格相关错误，未知错误不暴露细节
+func respondFlowerSKUError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case errFlowerNotFound, errFlowerSKUNotFound:
+		jsonResponse(c, http.StatusNotFound, err.Error(), nil)
+	default:
+		jsonResponse(c, http.StatusInternalServerError, fallback, nil)
+	}
+}
+
+// 获取鲜花的规格维度和 SKU
+func merchantGetFlowerSKUsHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		flower, err := findMerchantFlower(db, c.MustGet("merchantID").(uint), c.Param("id"))
+		if err != nil {
+			respondFlowerSKUError(c, err, "获取规格失败")
+			return
+		}
+		resolveFlowerImages(store, &flower)
+		jsonResponse(c, http.StatusOK, "获取成功", gin.H{"options": flower.Options, "skus": flower.SKUs})
+	}
+}
+
+// 整体设置鲜花的规格维度和 SKU
+func merchantSaveFlowerSKUsHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		merchantID := c.MustGet("merchantID").(uint)
+		var req flowerVariantsInput
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+		// 锁住鲜花行后再校验和保存，与部分更新鲜花的价格、库存互斥
+		var flower models.Flower
+		var failed []fieldError
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if flower, err = lockMerchantFlower(tx, merchantID, c.Param("id")); err != nil {
+				return err
+			}
+			if failed = req.validate(flower); len(failed) > 0 {
+				return errFieldsInvalid
+			}
+			if err := saveFlowerVariants(tx, flower.ID, req); err != nil {
+				return err
+			}
+			return syncFlowerCatalog(tx, store, flower.ID)
+		})
+		if err == errFieldsInvalid {
+			jsonResponse(c, http.StatusBadRequest, "参数校验失败", gin.H{"errors": failed})
+			return
+		}
+		if err != nil {
+			respondFlowerSKUError(c, err, "保存规格失败")
+			return
+		}
+		respondFlowerVariants(c, db, store, merchantID, flower.ID, "规格已保存")
+	}
+}
+
+// 修改单个 SKU 的价格、库存或图片，只修改请求中出现的字段
+func merchantPatchFlowerSKUHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		merchantID := c.MustGet("merchantID").(uint)
+		var req struct {
+			Price   *float64 `json:"price"`
+			Stock   *int     `json:"stock"`
+			ImageID *uint    `json:"image_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+		updates := map[string]interface{}{}
+		if req.Price != nil {
+			updates["price"] = *req.Price
+		}
+		if req.Stock != nil {
+			updates["stock"] = *req.Stock
+		}
+		if req.ImageID != nil {
+			updates["image_id"] = *req.ImageID
+		}
+		var flower models.Flower
+		var failed []fieldError
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if flower, err = lockMerchantFlower(tx, merchantID, c.Param("id")); err != nil {
+				return err
+			}
+			if failed = checkSKUPrice(flower, "", req.Price, req.Stock, req.ImageID); len(failed) > 0 {
+				return errFieldsInvalid
+			}
+			sku, err := findFlowerSKU(tx, flower.ID, idParam(c, "sku_id"))
+			if err != nil || len(updates) == 0 {
+				return err
+			}
+			if err := tx.Model(&sku).Updates(updates).Error; err != nil {
+				return err
+			}
+			if err := syncFlowerSKUTotals(tx, flower.ID); err != nil {
+				return err
+			}
+			return syncFlowerCatalog(tx, store, flower.ID)
+		})
+		if err == errFieldsInvalid {
+			jsonResponse(c, http.StatusBadRequest, "参数校验失败", gin.H{"errors": failed})
+			return
+		}
+		if err != nil {
+			respondFlowerSKUError(c, err, "修改规格失败")
+			return
+		}
+		respondFlowerVariants(c, db, store, merchantID, flower.ID, "规格已更新")
+	}
+}
+
+// 删除鲜花的全部规格，鲜花保留当前的最低价和库存之和
+func merchantDeleteFlowerSKUsHandler(db *gorm.DB, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		merchantID := c.MustGet("merchantID").(uint)
+		var flower models.Flower
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if flower, err = lockMerchantFlower(tx, merchantID, c.Param("id")); err != nil {
+				return err
+			}
+			if err := tx.Where("flower_id = ?", flower.ID).Delete(&models.FlowerOption{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("flower_id = ?", flower.ID).Delete(&models.FlowerSKU{}).Error; err != nil {
+				return err
+			}
+			return syncFlowerCatalog(tx, store, flower.ID)
+		})
+		if err != nil {
+			respondFlowerSKUError(c, err, "删除规格失败")
+			return
+		}
+		respondFlowerVariants(c, db, store, merchantID, flower.ID, "规格已删除")
+	}
+}
@@ -0,0 +1,280 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// catalogCount 鲜花在公共商品目录中的条目数
+func (s *testServer) catalogCount(flowerID uint) int64 {
+	s.t.Helper()
+	var n int64
+	if err := s.db.Model(&models.Goods{}).Where("flower_id = ?", flowerID).Count(&n).Error; err != nil {
+		s.t.Fatal(err)
+	}
+	return n
+}
+
+func TestMerchantFlowerCRUD(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.merchant("shop")
+
+	resp := s.upload(http.MethodPost, "/api/public/v1/merchants/flowers", token, map[string]string{
+		"name": "红玫瑰", "price": "99", "stock": "5", "category_id": "3", "description": "十一支", "status": "1",
+	}, testJPEG(t))
+	expect(t, resp, http.StatusCreated)
+	var created models.Flower
+	resp.decode(t, &created)
+	if created.ID == 0 || len(created.Images) != 1 || created.Images[0].URL == "" {
+		t.Fatalf("created = %+v, want one image with url", created)
+	}
+	if s.catalogCount(created.ID) != 1 {
+		t.Fatal("published flower not synced to catalog")
+	}
+	path := fmt.Sprintf("/api/public/v1/merchants/flowers/%d", created.ID)
+
+	resp = s.call(http.MethodGet, path, token, nil)
+	expect(t, resp, http.StatusOK)
+	var got models.Flower
+	resp.decode(t, &got)
+	if got.Name != "红玫瑰" || got.Price != 99 || got.Stock != 5 || len(got.Images) != 1 {
+		t.Fatalf("get = %+v", got)
+	}
+
+	resp = s.call(http.MethodGet, "/api/public/v1/merchants/flowers", token, nil)
+	expect(t, resp, http.StatusOK)
+	var list struct {
+		Data struct {
+			List  []models.Flower `json:"list"`
+			Total int64           `json:"total"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body, &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Data.Total != 1 || len(list.Data.List) != 1 || list.Data.List[0].ID != created.ID {
+		t.Fatalf("list = %+v", list.Data)
+	}
+
+	// 不带图片的 PUT 只修改表单字段，保留原有图片
+	resp = s.upload(http.MethodPut, path, token, map[string]string{
+		"name": "白玫瑰", "price": "88", "stock": "7", "category_id": "3",
+	})
+	expect(t, resp, http.StatusOK)
+	resp.decode(t, &got)
+	if got.Name != "白玫瑰" || got.Price != 88 || got.Stock != 7 || got.Status != 1 || len(got.Images) != 1 {
+		t.Fatalf("put = %+v", got)
+	}
+
+	resp = s.call(http.MethodPatch, path, token, gin.H{"stock": 2, "status": 0})
+	expect(t, resp, http.StatusOK)
+	resp.decode(t, &got)
+	if got.Stock != 2 || got.Status != 0 || got.Price != 88 {
+		t.Fatalf("patch = %+v", got)
+	}
+	if s.catalogCount(created.ID) != 0 {
+		t.Fatal("off-shelf flower still in catalog")
+	}
+
+	expect(t, s.call(http.MethodPut, path+"/status", token, gin.H{"status": 1}), http.StatusOK)
+	if s.catalogCount(created.ID) != 1 {
+		t.Fatal("flower not back in catalog after publishing")
+	}
+}
+
+func TestMerchantFlowerValidation(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.merchant("shop")
+
+	expect(t, s.upload(http.MethodPost, "/api/public/v1/merchants/flowers", token, map[string]string{"price": "1"}, testJPEG(t)), http.StatusBadRequest)
+	expect(t, s.upload(http.MethodPost, "/api/public/v1/merchants/flowers", token, map[string]string{"name": "无图"}), http.StatusBadRequest)
+	expect(t, s.upload(http.MethodPost, "/api/public/v1/merchants/flowers", token, map[string]string{"name": "假图"}, []byte("<?php echo 1;")), http.StatusBadRequest)
+
+	var n int64
+	s.db.Model(&models.Flower{}).Count(&n)
+	if n != 0 {
+		t.Fatalf("flowers = %d after rejected uploads, want 0", n)
+	}
+}
+
+func TestMerchantFlowerOwnership(t *testing.T) {
+	s := newTestServer(t)
+	owner, _ := s.merchant("owner")
+	_, other := s.merchant("other")
+	flower := s.flower(owner.ID, "红玫瑰", 99, 5)
+	path := fmt.Sprintf("/api/public/v1/merchants/flowers/%d", flower.ID)
+
+	expect(t, s.call(http.MethodGet, path, other, nil), http.StatusNotFound)
+	expect(t, s.call(http.MethodPatch, path, other, gin.H{"stock": 0}), http.StatusNotFound)
+	expect(t, s.upload(http.MethodPut, path, other, map[string]string{"name": "改名"}), http.StatusNotFound)
+	expect(t, s.call(http.MethodPut, path+"/status", other, gin.H{"status": 1}), http.StatusNotFound)
+
+	var got models.Flower
+	s.db.First(&got, flower.ID)
+	if got.Name != "红玫瑰" || got.Stock != 5 {
+		t.Fatalf("flower changed by another merchant: %+v", got)
+	}
+}
+
+func TestMerchantFlowerStatusOnlyTouchesStatus(t *testing.T) {
+	s := newTestServer(t)
+	merchant, token := s.merchant("shop")
+	flower := s.flower(merchant.ID, "红玫瑰", 99, 5)
+	path := fmt.Sprintf("/api/public/v1/merchants/flowers/%d/status", flower.ID)
+
+	resp := s.call(http.MethodPut, fmt.Sprintf("/api/public/v1/merchants/flowers/%d/skus", flower.ID), token, gin.H{
+		"options": []gin.H{{"name": "支数", "values": []string{"11支", "19支"}}},
+		"skus": []gin.H{
+			{"values": []string{"11支"}, "price": 99, "stock": 2},
+			{"values": []string{"19支"}, "price": 159, "stock": 3},
+		},
+	})
+	expect(t, resp, http.StatusOK)
+	var before models.Flower
+	s.db.First(&before, flower.ID)
+
+	expect(t, s.call(http.MethodPut, path, token, gin.H{}), http.StatusBadRequest)
+	expect(t, s.call(http.MethodPut, path, token, gin.H{"status": 2}), http.StatusBadRequest)
+
+	expect(t, s.call(http.MethodPut, path, token, gin.H{"status": 0}), http.StatusOK)
+	var got models.Flower
+	s.db.First(&got, flower.ID)
+	if got.Status != 0 || got.Stock != before.Stock || got.Price != before.Price || got.Name != before.Name {
+		t.Fatalf("after unshelf = %+v, before = %+v", got, before)
+	}
+	if s.catalogCount(flower.ID) != 0 {
+		t.Fatal("off-shelf flower still in catalog")
+	}
+	expect(t, s.call(http.MethodPut, path, token, gin.H{"status": 1}), http.StatusOK)
+	s.db.First(&got, flower.ID)
+	if got.Status != 1 || got.Stock != 5 || got.Price != 99 {
+		t.Fatalf("after publish = %+v", got)
+	}
+}
+
+func TestMerchantFlowerCatalogSyncFailureRollsBack(t *testing.T) {
+	s := newTestServer(t)
+	merchant, token := s.merchant("shop")
+	flower := s.flower(merchant.ID, "红玫瑰", 99, 5)
+	s.db.Model(&flower).Update("status", 0)
+	path := fmt.Sprintf("/api/public/v1/merchants/flowers/%d", flower.ID)
+
+	// 目录写入失败时鲜花本身的修改一起回滚，不会出现已上架却不在目录中的鲜花
+	if err := s.db.Migrator().DropTable("goods_search"); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, s.call(http.MethodPut, path+"/status", token, gin.H{"status": 1}), http.StatusInternalServerError)
+	expect(t, s.call(http.MethodPatch, path, token, gin.H{"name": "白玫瑰", "status": 1}), http.StatusInternalServerError)
+
+	var got models.Flower
+	s.db.First(&got, flower.ID)
+	if got.Status != 0 || got.Name != "红玫瑰" {
+		t.Fatalf("flower = %+v after failed catalog sync", got)
+	}
+	if s.catalogCount(flower.ID) != 0 {
+		t.Fatal("partial catalog entry left after failed sync")
+	}
+}
+
+func TestSweepOrphanImages(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	merchant, _ := s.merchant("shop")
+	flower := s.flower(merchant.ID, "红玫瑰", 99, 5)
+	deleted := s.flower(merchant.ID, "白玫瑰", 99, 5)
+	images := []models.FlowerImage{
+		{FlowerID: flower.ID, Path: "flowers/used.jpg", MidPath: "flowers/used_mid.jpg"},
+		{FlowerID: deleted.ID, Path: "legacy.jpg"},
+	}
+	if err := s.db.Create(&images).Error; err != nil {
+		t.Fatal(err)
+	}
+	s.db.Delete(&deleted)
+
+	keys := []string{
+		"flowers/used.jpg", "flowers/used_mid.jpg", "legacy.jpg", // 被引用，包括已删除鲜花的图片
+		"flowers/orphan.jpg", "orphan.jpg", // 鲜花目录和根目录下没有引用的文件
+		"avatars/a.jpg", // 其他目录不属于鲜花图片
+	}
+	for _, key := range keys {
+		if err := s.store.Put(ctx, key, bytes.NewReader(testJPEG(t)), 0, "image/jpeg"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 刚写入的文件还在宽限期内
+	if n, err := sweepOrphanImages(ctx, s.db, s.store, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Fatalf("sweep within grace = %d, %v", n, err)
+	}
+	if n, err := sweepOrphanImages(ctx, s.db, s.store, time.Now().Add(time.Hour)); err != nil || n != 2 {
+		t.Fatalf("sweep = %d, %v; want 2", n, err)
+	}
+	var left []string
+	s.store.List(ctx, "", func(obj storage.Object) error {
+		left = append(left, obj.Key)
+		return nil
+	})
+	sort.Strings(left)
+	want := []string{"avatars/a.jpg", "flowers/used.jpg", "flowers/used_mid.jpg", "legacy.jpg"}
+	if fmt.Sprint(left) != fmt.Sprint(want) {
+		t.Fatalf("left = %v, want %v", left, want)
+	}
+}
+
+func TestConcurrentFlowerPatchAndSKUs(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) { testConcurrentFlowerPatchAndSKUs(t, newTestServer(t)) })
+	t.Run("mysql", func(t *testing.T) { testConcurrentFlowerPatchAndSKUs(t, newMySQLTestServer(t)) })
+}
+
+// 设置规格与部分更新价格、库存同时进行时，有规格的鲜花价格和库存必须来自 SKU
+func testConcurrentFlowerPatchAndSKUs(t *testing.T, s *testServer) {
+	const rounds = 8
+	merchant, token := s.merchant("shop")
+	flower := s.flower(merchant.ID, "红玫瑰", 50, 1)
+	path := fmt.Sprintf("/api/public/v1/merchants/flowers/%d", flower.ID)
+	skus := gin.H{
+		"options": []gin.H{{"name": "支数", "values": []string{"11支", "19支"}}},
+		"skus": []gin.H{
+			{"values": []string{"11支"}, "price": 99, "stock": 2},
+			{"values": []string{"19支"}, "price": 159, "stock": 3},
+		},
+	}
+
+	var wg sync.WaitGroup
+	codes := make(chan int, rounds*2)
+	for i := 0; i < rounds; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			codes <- s.call(http.MethodPatch, path, token, gin.H{"price": 1, "stock": 1}).Code
+		}()
+		go func() {
+			defer wg.Done()
+			codes <- s.call(http.MethodPut, path+"/skus", token, skus).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK && code != http.StatusBadRequest {
+			t.Fatalf("status = %d", code)
+		}
+	}
+
+	var got models.Flower
+	s.db.First(&got, flower.ID)
+	if got.Price != 99 || got.Stock != 5 {
+		t.Fatalf("flower price = %v, stock = %d; want the SKU totals 99 and 5", got.Price, got.Stock)
+	}
+}
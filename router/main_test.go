@@ -0,0 +1,305 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/payment"
+	"github.com/LookAt-MeNow/flowers/sql"
+	"github.com/LookAt-MeNow/flowers/storage"
+	"github.com/LookAt-MeNow/flowers/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// SetupRouter 按相对路径读取 data 目录，测试统一在仓库根目录运行
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	if err := os.Chdir(".."); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+const (
+	testAdminUsername = "root"
+	testPassword      = "secret123"
+	testPaySecret     = "test-secret"
+)
+
+// testServer 使用内存 SQLite 和本地存储的完整路由
+type testServer struct {
+	t      *testing.T
+	db     *gorm.DB
+	store  storage.Storage
+	pay    *payment.Mock
+	router *gin.Engine
+}
+
+// testResponse 对应 jsonResponse 的响应结构
+type testResponse struct {
+	Code    int
+	Body    []byte
+	Message json.RawMessage `json:"message"`
+	Meta    models.Meta     `json:"meta"`
+}
+
+// decode 把 message 解析到 v
+func (r testResponse) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Message, v); err != nil {
+		t.Fatalf("decode message %s: %v", r.Message, err)
+	}
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	db, err := sql.NewMemoryDB()
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	return newTestServerWithDB(t, db)
+}
+
+// newMySQLTestServer 连接 FLOWERS_TEST_MYSQL_DSN 指定的 MySQL 测试库，未设置时跳过。
+// 该库的所有表会被删除后重建，只能使用专门的测试库
+func newMySQLTestServer(t *testing.T) *testServer {
+	t.Helper()
+	dsn := os.Getenv("FLOWERS_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("FLOWERS_TEST_MYSQL_DSN not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open mysql: %v", err)
+	}
+	if _, err := sql.Rollback(db, sql.LatestVersion()); err != nil {
+		t.Fatalf("reset mysql: %v", err)
+	}
+	if _, err := sql.Migrate(db); err != nil {
+		t.Fatalf("migrate mysql: %v", err)
+	}
+	return newTestServerWithDB(t, db)
+}
+
+// newTestServerWithDB 在已迁移的 db 上导入种子数据并创建超级管理员
+func newTestServerWithDB(t *testing.T, db *gorm.DB) *testServer {
+	t.Helper()
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if _, err := sql.ImportCategories(db, "data/categories.json"); err != nil {
+		t.Fatalf("import categories: %v", err)
+	}
+	if _, err := sql.ImportHomeContent(db, "data"); err != nil {
+		t.Fatalf("import home content: %v", err)
+	}
+
+	store, err := storage.NewLocal(t.TempDir(), "http://img.test/media")
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+
+	cfg := &sql.Config{}
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.Auth.TokenExpire = 1
+	cfg.WeChat.Provider = "fake"
+	cfg.Order.PaymentTimeout = 30
+	cfg.Upload.MaxSize = 1
+	cfg.Upload.MaxRequestSize = 4
+	cfg.Upload.MaxCount = 3
+
+	hash, err := utils.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Admin{Username: testAdminUsername, Password: hash, Role: models.RoleSuperAdmin}).Error; err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+
+	// 模拟支付不发送回调，测试自己签名后调用回调接口
+	pay := payment.NewMock(payment.MockConfig{Mode: payment.MockModeManual, Secret: testPaySecret})
+
+	return &testServer{t: t, db: db, store: store, pay: pay, router: SetupRouter(db, cfg, store, pay)}
+}
+
+// serve 执行请求并解析响应
+func (s *testServer) serve(req *http.Request, token string) testResponse {
+	s.t.Helper()
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	resp := testResponse{Code: w.Code, Body: w.Body.Bytes()}
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			s.t.Fatalf("%s %s: invalid response %q: %v", req.Method, req.URL, w.Body.String(), err)
+		}
+	}
+	return resp
+}
+
+// call 发送 JSON 请求
+func (s *testServer) call(method, path, token string, body interface{}) testResponse {
+	s.t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set("Content-Type", "application/json")
+	return s.serve(req, token)
+}
+
+// upload 发送 multipart 表单，images 依次作为 images 字段的 JPEG 文件
+func (s *testServer) upload(method, path, token string, fields map[string]string, images ...[]byte) testResponse {
+	s.t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	for i, data := range images {
+		w, err := mw.CreateFormFile("images", fmt.Sprintf("%d.jpg", i))
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	mw.Close()
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return s.serve(req, token)
+}
+
+// expect 校验响应状态码
+func expect(t *testing.T, resp testResponse, code int) {
+	t.Helper()
+	if resp.Code != code {
+		t.Fatalf("status = %d (%s), want %d", resp.Code, resp.Meta.Msg, code)
+	}
+}
+
+func (s *testServer) login(path string, body interface{}) string {
+	s.t.Helper()
+	resp := s.call(http.MethodPost, "/api/public/v1/auth/"+path, "", body)
+	expect(s.t, resp, http.StatusOK)
+	var out struct {
+		Token string `json:"token"`
+	}
+	resp.decode(s.t, &out)
+	if out.Token == "" {
+		s.t.Fatalf("%s: empty token", path)
+	}
+	return out.Token
+}
+
+func (s *testServer) adminToken() string {
+	return s.login("admin/login", gin.H{"username": testAdminUsername, "password": testPassword})
+}
+
+// registerMerchant 注册商家但不审核
+func (s *testServer) registerMerchant(username string) models.Merchant {
+	s.t.Helper()
+	resp := s.call(http.MethodPost, "/api/public/v1/auth/merchants/register", "", gin.H{
+		"username": username, "password": testPassword, "shop_name": username + "的花店",
+		"email": username + "@example.com", "phone": "13800000000",
+	})
+	expect(s.t, resp, http.StatusCreated)
+	var merchant models.Merchant
+	if err := s.db.Where("username = ?", username).First(&merchant).Error; err != nil {
+		s.t.Fatal(err)
+	}
+	return merchant
+}
+
+// merchant 注册并审核通过一个商家，返回商家和登录令牌
+func (s *testServer) merchant(username string) (models.Merchant, string) {
+	s.t.Helper()
+	merchant := s.registerMerchant(username)
+	resp := s.call(http.MethodPost, fmt.Sprintf("/api/public/v1/admin/merchants/%d/approve", merchant.ID), s.adminToken(), gin.H{"reason": "ok"})
+	expect(s.t, resp, http.StatusOK)
+	return merchant, s.login("merchants/login", gin.H{"username": username, "password": testPassword})
+}
+
+// user 使用 fake 身份渠道登录买家
+func (s *testServer) user(code string) string {
+	return s.login("users/login", gin.H{"code": code})
+}
+
+// flower 直接写入一条已上架的鲜花
+func (s *testServer) flower(merchantID uint, name string, price float64, stock int) models.Flower {
+	s.t.Helper()
+	flower := models.Flower{MerchantID: merchantID, Name: name, Price: price, Stock: stock, CategoryID: 3, Status: 1}
+	if err := s.db.Create(&flower).Error; err != nil {
+		s.t.Fatal(err)
+	}
+	return flower
+}
+
+// deliverySlot 为商家开放明天的配送时段，返回时段 ID 和日期
+func (s *testServer) deliverySlot(merchantToken string, capacity int) (uint, string) {
+	s.t.Helper()
+	resp := s.call(http.MethodPut, "/api/public/v1/merchants/delivery/settings", merchantToken, gin.H{
+		"daily_capacity": capacity, "same_day_cutoff": "23:59", "max_advance_days": 30,
+	})
+	expect(s.t, resp, http.StatusOK)
+	resp = s.call(http.MethodPost, "/api/public/v1/merchants/delivery/slots", merchantToken, gin.H{
+		"start_time": "09:00", "end_time": "12:00", "capacity": capacity,
+	})
+	expect(s.t, resp, http.StatusCreated)
+	var slot models.DeliverySlot
+	resp.decode(s.t, &slot)
+	return slot.ID, time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+}
+
+// address 为买家添加收货地址
+func (s *testServer) address(userToken string) uint {
+	s.t.Helper()
+	resp := s.call(http.MethodPost, "/api/public/v1/my/addresses", userToken, gin.H{
+		"recipient_name": "张三", "phone": "13800000000", "detail": "朝阳路1号",
+		"province_code": "11", "city_code": "1101", "district_code": "110105",
+	})
+	expect(s.t, resp, http.StatusCreated)
+	var addr models.Address
+	resp.decode(s.t, &addr)
+	return addr.ID
+}
+
+// testJPEG 生成一张小 JPEG 图片
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 48; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 5), 120, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,51 @@
+package router
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/LookAt-MeNow/flowers/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// --------------------------------------图片访问
+
+// mediaRoute 图片访问路由，upload.baseURL 默认指向这里
+const mediaRoute = "/media"
+
+// mediaCacheControl 图片 key 每次上传都不同，内容不会变化，可以长期缓存
+const mediaCacheControl = "public, max-age=31536000, immutable"
+
+// 读取存储中的图片，支持 ETag/Last-Modified 协商缓存和 Range 请求
+func mediaHandler(store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// key 中不允许出现 ..、反斜杠和绝对路径
+		key, err := storage.CleanKey(strings.TrimPrefix(c.Param("key"), "/"))
+		if err != nil {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		f, obj, err := store.Get(c.Request.Context(), key)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("read media %s failed: %v", key, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		header := c.Writer.Header()
+		if obj.ContentType != "" {
+			header.Set("Content-Type", obj.ContentType)
+		}
+		header.Set("ETag", obj.ETag)
+		header.Set("Cache-Control", mediaCacheControl)
+		header.Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(c.Writer, c.Request, "", obj.ModTime, f)
+	}
+}
@@ -0,0 +1,484 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/sql"
+	"github.com/LookAt-MeNow/flowers/storage"
+	"github.com/LookAt-MeNow/flowers/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------------------------------------订单
+
+// 状态变更的操作人类型
+const (
+	operatorUser     = "user"
+	operatorMerchant = "merchant"
+	operatorAdmin    = "admin"
+	operatorSystem   = "system"
+)
+
+var (
+	errIllegalTransition = errors.New("当前订单状态不允许该操作")
+	errCartEmpty         = errors.New("请选择要结算的商品")
+	errCartUnavailable   = errors.New("部分商品已下架或库存不足")
+	errMixedMerchants    = errors.New("不同店铺的商品请分开结算")
+)
+
+// transitionOrder 在事务内变更订单状态并写入流转记录
+// 更新时带上原状态条件，并发修改时只有一个请求会成功
+func transitionOrder(tx *gorm.DB, order *models.Order, to, operatorType string, operatorID uint, remark string) error {
+	from := order.Status
+	if !models.CanTransitionOrder(from, to) {
+		return errIllegalTransition
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": to}
+	switch to {
+	case models.OrderStatusPaid:
+		updates["paid_at"] = now
+	case models.OrderStatusCompleted:
+		updates["completed_at"] = now
+	case models.OrderStatusCancelled:
+		updates["cancelled_at"] = now
+	}
+
+	result := tx.Model(&models.Order{}).Where("id = ? AND status = ?", order.ID, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errIllegalTransition
+	}
+
+	if err := tx.Create(&models.OrderStatusHistory{
+		OrderID:      order.ID,
+		FromStatus:   from,
+		ToStatus:     to,
+		OperatorType: operatorType,
+		OperatorID:   operatorID,
+		Remark:       remark,
+	}).Error; err != nil {
+		return err
+	}
+
+	order.Status = to
+	switch to {
+	case models.OrderStatusPaid:
+		order.PaidAt = &now
+	case models.OrderStatusCompleted:
+		order.CompletedAt = &now
+	case models.OrderStatusCancelled:
+		order.CancelledAt = &now
+	}
+	return nil
+}
+
+// reserveStock 下单时扣减库存，库存不足时条件更新不会命中任何行；有规格的鲜花扣减 SKU 的库存
+func reserveStock(tx *gorm.DB, item models.OrderItem) error {
+	var result *gorm.DB
+	if item.SKUID != 0 {
+		onSale := tx.Model(&models.Flower{}).Select("id").Where("status = 1")
+		result = tx.Model(&models.FlowerSKU{}).
+			Where("id = ? AND flower_id = ? AND stock >= ? AND flower_id IN (?)", item.SKUID, item.FlowerID, item.Quantity, onSale).
+			UpdateColumn("stock", gorm.Expr("stock - ?", item.Quantity))
+	} else if item.FlowerID != 0 {
+		result = tx.Model(&models.Flower{}).
+			Where("id = ? AND status = 1 AND stock >= ?", item.FlowerID, item.Quantity).
+			UpdateColumn("stock", gorm.Expr("stock - ?", item.Quantity))
+	} else {
+		result = tx.Model(&models.Goods{}).
+			Where("goods_id = ? AND goods_number >= ?", item.GoodsID, item.Quantity).
+			UpdateColumn("goods_number", gorm.Expr("goods_number - ?", item.Quantity))
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInsufficientStock
+	}
+	if item.SKUID != 0 {
+		return syncFlowerSKUTotals(tx, item.FlowerID)
+	}
+	if item.FlowerID != 0 {
+		return syncCatalogStock(tx, item.FlowerID)
+	}
+	return nil
+}
+
+// releaseStock 订单取消时归还库存
+func releaseStock(tx *gorm.DB, orderID uint) error {
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", orderID).Find(&items).Error; err != nil {
+		return err
+	}
+	for _, item := range items {
+		var err error
+		if item.SKUID != 0 {
+			// SKU 已删除时不再归还
+			err = tx.Model(&models.FlowerSKU{}).Where("id = ?", item.SKUID).
+				UpdateColumn("stock", gorm.Expr("stock + ?", item.Quantity)).Error
+			if err == nil {
+				err = syncFlowerSKUTotals(tx, item.FlowerID)
+			}
+		} else if item.FlowerID != 0 {
+			err = tx.Model(&models.Flower{}).Where("id = ?", item.FlowerID).
+				UpdateColumn("stock", gorm.Expr("stock + ?", item.Quantity)).Error
+			if err == nil {
+				err = syncCatalogStock(tx, item.FlowerID)
+			}
+		} else {
+			err = tx.Model(&models.Goods{}).Where("goods_id = ?", item.GoodsID).
+				UpdateColumn("goods_number", gorm.Expr("goods_number + ?", item.Quantity)).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelOrder 取消待支付订单并释放预留的库存
+func cancelOrder(tx *gorm.DB, order *models.Order, operatorType string, operatorID uint, remark string) error {
+	if err := transitionOrder(tx, order, models.OrderStatusCancelled, operatorType, operatorID, remark); err != nil {
+		return err
+	}
+	return releaseStock(tx, order.ID)
+}
+
+// orderErrorStatus 订单业务错误对应的 HTTP 状态码
+func orderErrorStatus(err error) int {
+	switch err {
+	case errIllegalTransition:
+		return http.StatusConflict
+	case errCartEmpty, errCartUnavailable, errMixedMerchants, errInsufficientStock:
+		return http.StatusBadRequest
+	case errDeliverySlotFull, errDeliveryDayFull:
+		return http.StatusConflict
+	default:
+		if isDeliveryError(err) {
+			return http.StatusBadRequest
+		}
+		return http.StatusInternalServerError
+	}
+}
+
+// respondOrderError 返回订单相关错误，未知错误不暴露细节
+func respondOrderError(c *gin.Context, err error, fallback string) {
+	status := orderErrorStatus(err)
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		msg = fallback
+	}
+	jsonResponse(c, status, msg, nil)
+}
+
+// 下单：把购物车中选中的商品生成订单
+func orderCheckoutHandler(db *gorm.DB, cfg *sql.Config, store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uint)
+		var req struct {
+			CartItemIDs []uint `json:"cart_item_ids"` // 为空时结算所有已勾选条目
+			Remark      string `json:"remark" binding:"max=255"`
+
+			DeliveryDate   string `json:"delivery_date"` // YYYY-MM-DD，店铺订单必填
+			DeliverySlotID uint   `json:"delivery_slot_id"`
+			CardMessage    string `json:"card_message" binding:"max=500"`
+
+			// 收货信息：传 address_id 时使用地址簿中的地址，否则直接填写
+			AddressID        uint   `json:"address_id"`
+			RecipientName    string `json:"recipient_name" binding:"max=50"`
+			RecipientPhone   string `json:"recipient_phone" binding:"max=20"`
+			RecipientAddress string `json:"recipient_address" binding:"max=255"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+		if req.AddressID != 0 {
+			addr, err := findUserAddress(db, userID, req.AddressID)
+			if err != nil {
+				jsonResponse(c, http.StatusBadRequest, errAddressNotFound.Error(), nil)
+				return
+			}
+			req.RecipientName, req.RecipientPhone, req.RecipientAddress = addr.RecipientName, addr.Phone, addr.FullAddress()
+		}
+		if req.RecipientName == "" || req.RecipientPhone == "" || req.RecipientAddress == "" {
+			jsonResponse(c, http.StatusBadRequest, "请填写收货人信息", nil)
+			return
+		}
+
+		var order models.Order
+		err := db.Transaction(func(tx *gorm.DB) error {
+			query := tx.Where("user_id = ?", userID)
+			if len(req.CartItemIDs) > 0 {
+				query = query.Where("id IN ?", req.CartItemIDs)
+			} else {
+				query = query.Where("selected = ?", true)
+			}
+			var cartItems []models.CartItem
+			if err := query.Find(&cartItems).Error; err != nil {
+				return err
+			}
+			if len(cartItems) == 0 {
+				return errCartEmpty
+			}
+
+			views, err := resolveCartItems(tx, store, cartItems)
+			if err != nil {
+				return err
+			}
+
+			order = models.Order{
+				OrderNo:    utils.GenerateOrderNo(),
+				UserID:     userID,
+				MerchantID: views[0].MerchantID,
+				Status:     models.OrderStatusPendingPayment,
+				Remark:     req.Remark,
+
+				RecipientName:    req.RecipientName,
+				RecipientPhone:   req.RecipientPhone,
+				RecipientAddress: req.RecipientAddress,
+				CardMessage:      req.CardMessage,
+			}
+			var total float64
+			for _, v := range views {
+				if !v.Available() {
+					return errCartUnavailable
+				}
+				if v.MerchantID != order.MerchantID {
+					return errMixedMerchants
+				}
+				subtotal := roundPrice(v.Price * float64(v.Quantity))
+				order.Items = append(order.Items, models.OrderItem{
+					GoodsID:   v.GoodsID,
+					AttrID:    v.AttrID,
+					FlowerID:  v.FlowerID,
+					SKUID:     v.SKUID,
+					Name:      v.Name,
+					AttrValue: v.AttrValue,
+					Image:     v.Image,
+					Price:     v.Price,
+					Quantity:  v.Quantity,
+					Subtotal:  subtotal,
+				})
+				total += subtotal
+				order.ItemCount += v.Quantity
+			}
+			order.TotalAmount = roundPrice(total)
+
+			// 店铺订单需选择配送日期和时段，平台商品由平台统一配送
+			if order.MerchantID != 0 {
+				if req.DeliveryDate == "" || req.DeliverySlotID == 0 {
+					return errDeliverySlotRequired
+				}
+				slot, err := reserveDeliverySlot(tx, order.MerchantID, req.DeliveryDate, req.DeliverySlotID, time.Now())
+				if err != nil {
+					return err
+				}
+				order.DeliveryDate = req.DeliveryDate
+				order.DeliverySlotID = slot.ID
+				order.DeliverySlot = slot.Label()
+			}
+			expiresAt := time.Now().Add(time.Duration(cfg.Order.PaymentTimeout) * time.Minute)
+			order.ExpiresAt = &expiresAt
+
+			// 预留库存，任何一件不足都会回滚整个订单
+			for _, item := range order.Items {
+				if err := reserveStock(tx, item); err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Create(&order).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.OrderStatusHistory{
+				OrderID:      order.ID,
+				ToStatus:     order.Status,
+				OperatorType: operatorUser,
+				OperatorID:   userID,
+				Remark:       "创建订单",
+			}).Error; err != nil {
+				return err
+			}
+
+			// 已下单的条目从购物车移除
+			ids := make([]uint, 0, len(cartItems))
+			for _, item := range cartItems {
+				ids = append(ids, item.ID)
+			}
+			return tx.Where("id IN ?", ids).Delete(&models.CartItem{}).Error
+		})
+		if err != nil {
+			respondOrderError(c, err, "下单失败")
+			return
+		}
+		jsonResponse(c, http.StatusCreated, "下单成功", order)
+	}
+}
+
+// listOrders 分页查询订单，ownerColumn 为 user_id 或 merchant_id
+func listOrders(c *gin.Context, db *gorm.DB, ownerColumn string, ownerID uint) {
+	query := db.Model(&models.Order{}).Where(ownerColumn+" = ?", ownerID)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		jsonResponse(c, http.StatusInternalServerError, "获取订单总数失败", nil)
+		return
+	}
+	var orders []models.Order
+	if err := query.Preload("Items").Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&orders).Error; err != nil {
+		jsonResponse(c, http.StatusInternalServerError, "获取订单列表失败", nil)
+		return
+	}
+
+	jsonResponse(c, http.StatusOK, "获取成功", gin.H{
+		"list":      orders,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// getOrderDetail 查询订单详情及状态流转记录，ownerColumn 为 user_id 或 merchant_id
+func getOrderDetail(c *gin.Context, db *gorm.DB, ownerColumn string, ownerID uint) {
+	var order models.Order
+	if err := db.Preload("Items").Where("id = ? AND "+ownerColumn+" = ?", c.Param("id"), ownerID).First(&order).Error; err != nil {
+		jsonResponse(c, http.StatusNotFound, "订单不存在", nil)
+		return
+	}
+	var history []models.OrderStatusHistory
+	db.Where("order_id = ?", order.ID).Order("id").Find(&history)
+
+	jsonResponse(c, http.StatusOK, "获取成功", gin.H{
+		"order":   order,
+		"history": history,
+	})
+}
+
+// 买家订单列表
+func userListOrdersHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		listOrders(c, db, "user_id", c.MustGet("userID").(uint))
+	}
+}
+
+// 买家订单详情
+func userGetOrderHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		getOrderDetail(c, db, "user_id", c.MustGet("userID").(uint))
+	}
+}
+
+// userOrderActionHandler 买家取消订单、确认收货
+func userOrderActionHandler(db *gorm.DB, to, remark string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uint)
+		var order models.Order
+		if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&order).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "订单不存在", nil)
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if to == models.OrderStatusCancelled {
+				return cancelOrder(tx, &order, operatorUser, userID, remark)
+			}
+			return transitionOrder(tx, &order, to, operatorUser, userID, remark)
+		})
+		if err != nil {
+			respondOrderError(c, err, "操作失败")
+			return
+		}
+		jsonResponse(c, http.StatusOK, "操作成功", order)
+	}
+}
+
+// 商家订单列表
+func merchantListOrdersHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		listOrders(c, db, "merchant_id", c.MustGet("merchantID").(uint))
+	}
+}
+
+// 商家订单详情
+func merchantGetOrderHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		getOrderDetail(c, db, "merchant_id", c.MustGet("merchantID").(uint))
+	}
+}
+
+// 商家更新订单状态：备货、配送
+func merchantUpdateOrderStatusHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		merchantID := c.MustGet("merchantID").(uint)
+		updateOrderStatus(c, db, merchantID, operatorMerchant, merchantID)
+	}
+}
+
+// 平台商品订单列表，平台商品由平台统一配送
+func adminListPlatformOrdersHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		listOrders(c, db, "merchant_id", 0)
+	}
+}
+
+// 平台商品订单详情
+func adminGetPlatformOrderHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		getOrderDetail(c, db, "merchant_id", 0)
+	}
+}
+
+// 管理员更新平台商品订单状态：备货、配送，店铺订单由商家处理
+func adminUpdatePlatformOrderStatusHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		updateOrderStatus(c, db, 0, operatorAdmin, c.MustGet("adminID").(uint))
+	}
+}
+
+// updateOrderStatus 把 merchantID 名下的订单推进到备货或配送，merchantID 为 0 时为平台商品订单
+func updateOrderStatus(c *gin.Context, db *gorm.DB, merchantID uint, operatorType string, operatorID uint) {
+	var req struct {
+		Status string `json:"status" binding:"required,oneof=preparing delivering"`
+		Remark string `json:"remark" binding:"max=255"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+		return
+	}
+
+	var order models.Order
+	if err := db.Where("id = ? AND merchant_id = ?", c.Param("id"), merchantID).First(&order).Error; err != nil {
+		jsonResponse(c, http.StatusNotFound, "订单不存在", nil)
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return transitionOrder(tx, &order, req.Status, operatorType, operatorID, req.Remark)
+	})
+	if err != nil {
+		respondOrderError(c, err, "更新订单状态失败")
+		return
+	}
+	jsonResponse(c, http.StatusOK, "状态更新成功", order)
+}
@@ -0,0 +1,49 @@
+package router
+
+import (
+	"log"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"gorm.io/gorm"
+)
+
+// StartOrderExpiryWorker 定时取消超过支付截止时间的订单并释放库存
+func StartOrderExpiryWorker(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if n, err := cancelExpiredOrders(db, time.Now()); err != nil {
+				log.Printf("cancel expired orders failed: %v", err)
+			} else if n > 0 {
+				log.Printf("cancelled %d expired orders", n)
+			}
+		}
+	}()
+}
+
+// cancelExpiredOrders 取消 now 之前到期仍未支付的订单，返回取消的数量
+// 与支付回调并发时由 transitionOrder 的条件更新保证只有一方生效
+func cancelExpiredOrders(db *gorm.DB, now time.Time) (int, error) {
+	var orders []models.Order
+	if err := db.Where("status = ? AND expires_at < ?", models.OrderStatusPendingPayment, now).
+		Order("id").Limit(100).Find(&orders).Error; err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	for i := range orders {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return cancelOrder(tx, &orders[i], operatorSystem, 0, "支付超时自动取消")
+		})
+		if err == errIllegalTransition {
+			continue // 已被支付或取消
+		}
+		if err != nil {
+			return cancelled, err
+		}
+		cancelled++
+	}
+	return cancelled, nil
+}
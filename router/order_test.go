@@ -0,0 +1,358 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestCheckout(t *testing.T) {
+	s := newTestServer(t)
+	merchant, merchantToken := s.merchant("shop")
+	slotID, date := s.deliverySlot(merchantToken, 10)
+	flower := s.flower(merchant.ID, "红玫瑰", 19.9, 5)
+	token := s.user("buyer")
+	addressID := s.address(token)
+
+	expect(t, s.call(http.MethodPost, "/api/public/v1/my/orders", token, gin.H{"address_id": addressID}), http.StatusBadRequest)
+
+	expect(t, s.call(http.MethodPost, "/api/public/v1/my/cart", token, gin.H{"flower_id": flower.ID, "quantity": 2}), http.StatusOK)
+
+	// 店铺订单必须选择配送时段
+	expect(t, s.call(http.MethodPost, "/api/public/v1/my/orders", token, gin.H{"address_id": addressID}), http.StatusBadRequest)
+
+	resp := s.call(http.MethodPost, "/api/public/v1/my/orders", token, gin.H{
+		"address_id": addressID, "delivery_date": date, "delivery_slot_id": slotID, "card_message": "生日快乐",
+	})
+	expect(t, resp, http.StatusCreated)
+	var order models.Order
+	resp.decode(t, &order)
+	if order.Status != models.OrderStatusPendingPayment || order.MerchantID != merchant.ID {
+		t.Fatalf("order = %+v", order)
+	}
+	if order.TotalAmount != 39.8 || order.ItemCount != 2 || len(order.Items) != 1 {
+		t.Fatalf("order total = %v, count = %d, items = %d", order.TotalAmount, order.ItemCount, len(order.Items))
+	}
+	if order.RecipientName != "张三" || order.DeliverySlot != "09:00-12:00" || order.ExpiresAt == nil {
+		t.Fatalf("order delivery = %+v", order)
+	}
+
+	var got models.Flower
+	s.db.First(&got, flower.ID)
+	if got.Stock != 3 {
+		t.Fatalf("stock = %d after checkout, want 3", got.Stock)
+	}
+	if items := s.cart(token).Items; len(items) != 0 {
+		t.Fatalf("cart = %+v after checkout, want empty", items)
+	}
+
+	// 取消后释放库存
+	expect(t, s.call(http.MethodPost, fmt.Sprintf("/api/public/v1/my/orders/%d/cancel", order.ID), token, nil), http.StatusOK)
+	s.db.First(&got, flower.ID)
+	if got.Stock != 5 {
+		t.Fatalf("stock = %d after cancel, want 5", got.Stock)
+	}
+}
+
+func TestCheckoutRejectsStaleCart(t *testing.T) {
+	s := newTestServer(t)
+	merchant, merchantToken := s.merchant("shop")
+	slotID, date := s.deliverySlot(merchantToken, 10)
+	flower := s.flower(merchant.ID, "红玫瑰", 19.9, 5)
+	token := s.user("buyer")
+	addressID := s.address(token)
+	expect(t, s.call(http.MethodPost, "/api/public/v1/my/cart", token, gin.H{"flower_id": flower.ID, "quantity": 3}), http.StatusOK)
+
+	// 加入购物车后库存被其他订单占用
+	s.db.Model(&flower).Update("stock", 2)
+	checkout := gin.H{"address_id": addressID, "delivery_date": date, "delivery_slot_id": slotID}
+	expect(t, s.call(http.MethodPost, "/api/public/v1/my/orders", token, checkout), http.StatusBadRequest)
+
+	var orders int64
+	s.db.Model(&models.Order{}).Count(&orders)
+	var got models.Flower
+	s.db.First(&got, flower.ID)
+	if orders != 0 || got.Stock != 2 {
+		t.Fatalf("orders = %d, stock = %d after failed checkout; want 0, 2", orders, got.Stock)
+	}
+}
+
+// concurrentCheckout 让每个买家同时结算购物车，返回下单成功的数量
+func (s *testServer) concurrentCheckout(tokens []string, addressIDs []uint, slotID uint, date string) int {
+	s.t.Helper()
+	codes := make([]int, len(tokens))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range tokens {
+		body, err := json.Marshal(gin.H{"address_id": addressIDs[i], "delivery_date": date, "delivery_slot_id": slotID})
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/public/v1/my/orders", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tokens[i])
+
+		wg.Add(1)
+		go func(i int, req *http.Request) {
+			defer wg.Done()
+			<-start
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			codes[i] = w.Code
+		}(i, req)
+	}
+	close(start)
+	wg.Wait()
+
+	var created int
+	for i, code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusBadRequest, http.StatusConflict:
+			// 库存不足或配送时段约满
+		default:
+			s.t.Errorf("buyer %d: checkout status %d", i, code)
+		}
+	}
+	return created
+}
+
+// buyersWithCart 登录 n 个买家，各自添加地址并把 line 加入购物车
+func (s *testServer) buyersWithCart(n int, line gin.H) ([]string, []uint) {
+	s.t.Helper()
+	tokens := make([]string, n)
+	addressIDs := make([]uint, n)
+	for i := range tokens {
+		tokens[i] = s.user(fmt.Sprintf("buyer-%d", i))
+		addressIDs[i] = s.address(tokens[i])
+		expect(s.t, s.call(http.MethodPost, "/api/public/v1/my/cart", tokens[i], line), http.StatusOK)
+	}
+	return tokens, addressIDs
+}
+
+// 内存 SQLite 只有一个连接，事务实际依次执行；设置 FLOWERS_TEST_MYSQL_DSN 后在 MySQL 上并发执行
+func TestConcurrentCheckoutDoesNotOversellFlower(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) { testConcurrentCheckoutFlower(t, newTestServer(t)) })
+	t.Run("mysql", func(t *testing.T) { testConcurrentCheckoutFlower(t, newMySQLTestServer(t)) })
+}
+
+func TestConcurrentCheckoutDoesNotOversellSKU(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) { testConcurrentCheckoutSKU(t, newTestServer(t)) })
+	t.Run("mysql", func(t *testing.T) { testConcurrentCheckoutSKU(t, newMySQLTestServer(t)) })
+}
+
+func testConcurrentCheckoutFlower(t *testing.T, s *testServer) {
+	const buyers, stock = 12, 3
+	merchant, merchantToken := s.merchant("shop")
+	slotID, date := s.deliverySlot(merchantToken, 100)
+	flower := s.flower(merchant.ID, "红玫瑰", 19.9, stock)
+	tokens, addressIDs := s.buyersWithCart(buyers, gin.H{"flower_id": flower.ID, "quantity": 1})
+
+	created := s.concurrentCheckout(tokens, addressIDs, slotID, date)
+
+	var got models.Flower
+	s.db.First(&got, flower.ID)
+	if got.Stock < 0 || created > stock {
+		t.Fatalf("stock = %d, orders = %d; oversold a stock of %d", got.Stock, created, stock)
+	}
+	if got.Stock != stock-created {
+		t.Fatalf("stock = %d after %d orders, want %d", got.Stock, created, stock-created)
+	}
+	var orders int64
+	s.db.Model(&models.Order{}).Count(&orders)
+	if int(orders) != created {
+		t.Fatalf("orders in db = %d, successful responses = %d", orders, created)
+	}
+}
+
+func testConcurrentCheckoutSKU(t *testing.T, s *testServer) {
+	const buyers, stock = 12, 2
+	merchant, merchantToken := s.merchant("shop")
+	slotID, date := s.deliverySlot(merchantToken, 100)
+	flower := s.flower(merchant.ID, "红玫瑰", 19.9, 0)
+
+	resp := s.call(http.MethodPut, fmt.Sprintf("/api/public/v1/merchants/flowers/%d/skus", flower.ID), merchantToken, gin.H{
+		"options": []gin.H{{"name": "支数", "values": []string{"11支", "19支"}}},
+		"skus": []gin.H{
+			{"values": []string{"11支"}, "price": 99, "stock": stock},
+			{"values": []string{"19支"}, "price": 159, "stock": 5},
+		},
+	})
+	expect(t, resp, http.StatusOK)
+	var saved struct {
+		SKUs []models.FlowerSKU `json:"skus"`
+	}
+	resp.decode(t, &saved)
+	target, untouched := saved.SKUs[0], saved.SKUs[1]
+
+	tokens, addressIDs := s.buyersWithCart(buyers, gin.H{"flower_id": flower.ID, "sku_id": target.ID, "quantity": 1})
+
+	created := s.concurrentCheckout(tokens, addressIDs, slotID, date)
+
+	var sku models.FlowerSKU
+	s.db.First(&sku, target.ID)
+	if sku.Stock < 0 || created > stock {
+		t.Fatalf("sku stock = %d, orders = %d; oversold a stock of %d", sku.Stock, created, stock)
+	}
+	if sku.Stock != stock-created {
+		t.Fatalf("sku stock = %d after %d orders, want %d", sku.Stock, created, stock-created)
+	}
+	var other models.FlowerSKU
+	s.db.First(&other, untouched.ID)
+	var got models.Flower
+	s.db.First(&got, flower.ID)
+	if other.Stock != 5 || got.Stock != other.Stock+sku.Stock {
+		t.Fatalf("other sku stock = %d, flower total = %d", other.Stock, got.Stock)
+	}
+}
+
+func TestConcurrentCheckoutDoesNotOverbookSlot(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) { testConcurrentCheckoutSlot(t, newTestServer(t)) })
+	t.Run("mysql", func(t *testing.T) { testConcurrentCheckoutSlot(t, newMySQLTestServer(t)) })
+}
+
+func testConcurrentCheckoutSlot(t *testing.T, s *testServer) {
+	const buyers, capacity = 8, 2
+	merchant, merchantToken := s.merchant("shop")
+	slotID, date := s.deliverySlot(merchantToken, capacity)
+	// 没有配送设置行时也要按顺序计数
+	s.db.Where("merchant_id = ?", merchant.ID).Delete(&models.DeliverySetting{})
+	flower := s.flower(merchant.ID, "红玫瑰", 19.9, 100)
+	tokens, addressIDs := s.buyersWithCart(buyers, gin.H{"flower_id": flower.ID, "quantity": 1})
+
+	created := s.concurrentCheckout(tokens, addressIDs, slotID, date)
+
+	var booked int64
+	s.db.Model(&models.Order{}).Where("delivery_slot_id = ? AND delivery_date = ?", slotID, date).Count(&booked)
+	if created != capacity || booked != capacity {
+		t.Fatalf("orders = %d, booked = %d; want the slot capacity %d", created, booked, capacity)
+	}
+}
+
+func TestAdminFulfillsPlatformOrder(t *testing.T) {
+	s := newTestServer(t)
+	merchant, _ := s.merchant("shop")
+	token := s.adminToken()
+
+	platform := models.Order{OrderNo: "P1", UserID: 1, Status: models.OrderStatusPaid, TotalAmount: 10}
+	shop := models.Order{OrderNo: "P2", UserID: 1, MerchantID: merchant.ID, Status: models.OrderStatusPaid, TotalAmount: 10}
+	s.db.Create(&platform)
+	s.db.Create(&shop)
+
+	resp := s.call(http.MethodGet, "/api/public/v1/admin/platform-orders", token, nil)
+	expect(t, resp, http.StatusOK)
+	var list struct {
+		List []models.Order `json:"list"`
+	}
+	resp.decode(t, &list)
+	if len(list.List) != 1 || list.List[0].ID != platform.ID {
+		t.Fatalf("platform orders = %+v", list.List)
+	}
+
+	path := fmt.Sprintf("/api/public/v1/admin/platform-orders/%d/status", platform.ID)
+	expect(t, s.call(http.MethodPut, path, token, gin.H{"status": models.OrderStatusDelivering}), http.StatusConflict)
+	expect(t, s.call(http.MethodPut, path, token, gin.H{"status": models.OrderStatusPreparing}), http.StatusOK)
+	expect(t, s.call(http.MethodPut, path, token, gin.H{"status": models.OrderStatusDelivering}), http.StatusOK)
+
+	var history []models.OrderStatusHistory
+	s.db.Where("order_id = ?", platform.ID).Order("id").Find(&history)
+	if len(history) != 2 || history[1].OperatorType != operatorAdmin {
+		t.Fatalf("history = %+v", history)
+	}
+
+	// 店铺订单由商家处理
+	shopPath := fmt.Sprintf("/api/public/v1/admin/platform-orders/%d/status", shop.ID)
+	expect(t, s.call(http.MethodPut, shopPath, token, gin.H{"status": models.OrderStatusPreparing}), http.StatusNotFound)
+
+	// 没有 order:fulfill 权限的角色不能操作
+	hash, _ := utils.HashPassword(testPassword)
+	s.db.Create(&models.Admin{Username: "auditor", Password: hash, Role: models.RoleAuditor})
+	auditor := s.login("admin/login", gin.H{"username": "auditor", "password": testPassword})
+	expect(t, s.call(http.MethodGet, "/api/public/v1/admin/platform-orders", auditor, nil), http.StatusForbidden)
+}
+
+// afterNextQuery 在下一次查询 table 之后、同一事务内执行 fn，
+// 模拟其他订单在本次结算读取库存之后提交的扣减，结果不依赖数据库的并发能力。
+// fn 的写入属于结算事务，结算失败时会一起回滚
+func (s *testServer) afterNextQuery(table string, fn func(tx *gorm.DB)) {
+	var once sync.Once
+	name := "test:after_query_" + table
+	err := s.db.Callback().Query().After("gorm:query").Register(name, func(db *gorm.DB) {
+		if db.Statement.Table == table {
+			once.Do(func() { fn(db.Session(&gorm.Session{NewDB: true})) })
+		}
+	})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Cleanup(func() { s.db.Callback().Query().Remove(name) })
+}
+
+// 结算读到的库存已经过期时，条件扣减必须失败；直接扣减会把库存扣成 -1 并下单成功
+func TestCheckoutStockGuardAfterStaleRead(t *testing.T) {
+	s := newTestServer(t)
+	merchant, merchantToken := s.merchant("shop")
+	slotID, date := s.deliverySlot(merchantToken, 10)
+	flower := s.flower(merchant.ID, "红玫瑰", 19.9, 3)
+	tokens, addressIDs := s.buyersWithCart(1, gin.H{"flower_id": flower.ID, "quantity": 2})
+
+	s.afterNextQuery("flowers", func(tx *gorm.DB) {
+		tx.Exec("UPDATE flowers SET stock = 1 WHERE id = ?", flower.ID)
+	})
+	resp := s.call(http.MethodPost, "/api/public/v1/my/orders", tokens[0], gin.H{"address_id": addressIDs[0], "delivery_date": date, "delivery_slot_id": slotID})
+	expect(t, resp, http.StatusBadRequest)
+	if resp.Meta.Msg != errInsufficientStock.Error() {
+		t.Fatalf("message = %q, want %q", resp.Meta.Msg, errInsufficientStock.Error())
+	}
+
+	var got models.Flower
+	s.db.First(&got, flower.ID)
+	var orders int64
+	s.db.Model(&models.Order{}).Count(&orders)
+	if got.Stock != 3 || orders != 0 {
+		t.Fatalf("stock = %d, orders = %d after stale checkout; want 3, 0", got.Stock, orders)
+	}
+}
+
+func TestCheckoutSKUStockGuardAfterStaleRead(t *testing.T) {
+	s := newTestServer(t)
+	merchant, merchantToken := s.merchant("shop")
+	slotID, date := s.deliverySlot(merchantToken, 10)
+	flower := s.flower(merchant.ID, "红玫瑰", 19.9, 0)
+	resp := s.call(http.MethodPut, fmt.Sprintf("/api/public/v1/merchants/flowers/%d/skus", flower.ID), merchantToken, gin.H{
+		"options": []gin.H{{"name": "支数", "values": []string{"11支"}}},
+		"skus":    []gin.H{{"values": []string{"11支"}, "price": 99, "stock": 3}},
+	})
+	expect(t, resp, http.StatusOK)
+	var saved struct {
+		SKUs []models.FlowerSKU `json:"skus"`
+	}
+	resp.decode(t, &saved)
+	skuID := saved.SKUs[0].ID
+	tokens, addressIDs := s.buyersWithCart(1, gin.H{"flower_id": flower.ID, "sku_id": skuID, "quantity": 2})
+
+	s.afterNextQuery("flower_skus", func(tx *gorm.DB) {
+		tx.Exec("UPDATE flower_skus SET stock = 1 WHERE id = ?", skuID)
+	})
+	resp = s.call(http.MethodPost, "/api/public/v1/my/orders", tokens[0], gin.H{"address_id": addressIDs[0], "delivery_date": date, "delivery_slot_id": slotID})
+	expect(t, resp, http.StatusBadRequest)
+	if resp.Meta.Msg != errInsufficientStock.Error() {
+		t.Fatalf("message = %q, want %q", resp.Meta.Msg, errInsufficientStock.Error())
+	}
+
+	var sku models.FlowerSKU
+	s.db.First(&sku, skuID)
+	if sku.Stock != 3 {
+		t.Fatalf("sku stock = %d after stale checkout, want 3", sku.Stock)
+	}
+}
@@ -0,0 +1,374 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/payment"
+	"github.com/LookAt-MeNow/flowers/sql"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --------------------------------------支付
+
+var (
+	errAmountMismatch = errors.New("支付金额与订单金额不一致")
+	errRefundConflict = errors.New("订单已退款或正在退款")
+)
+
+// NewPaymentProvider 按配置创建支付渠道
+func NewPaymentProvider(cfg *sql.Config) (payment.Provider, error) {
+	switch cfg.Payment.Provider {
+	case "wechat":
+		wx := cfg.Payment.WeChat
+		return payment.NewWeChatPay(payment.WeChatPayConfig{
+			AppID:                 cfg.WeChat.AppID,
+			MchID:                 wx.MchID,
+			SerialNo:              wx.SerialNo,
+			PrivateKeyPath:        wx.PrivateKeyPath,
+			APIv3Key:              wx.APIv3Key,
+			PlatformPublicKeyPath: wx.PlatformPublicKeyPath,
+			PlatformSerial:        wx.PlatformSerial,
+			NotifyURL:             cfg.Payment.NotifyURL,
+		})
+	case "mock":
+		return payment.NewMock(payment.MockConfig{
+			Mode:      cfg.Payment.Mock.Mode,
+			Delay:     time.Duration(cfg.Payment.Mock.Delay) * time.Second,
+			Secret:    cfg.Payment.Mock.Secret,
+			NotifyURL: cfg.Payment.NotifyURL,
+			Refund:    cfg.Payment.Mock.Refund,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.Payment.Provider)
+	}
+}
+
+// toFen 金额转换为分
+func toFen(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// 发起支付：返回小程序 wx.requestPayment 所需参数
+func orderPayHandler(db *gorm.DB, pay payment.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("userID").(uint)
+		var order models.Order
+		if err := db.Preload("Items").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&order).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "订单不存在", nil)
+			return
+		}
+		if order.Status != models.OrderStatusPendingPayment {
+			jsonResponse(c, http.StatusConflict, "订单当前状态无法支付", nil)
+			return
+		}
+		if order.ExpiresAt != nil && order.ExpiresAt.Before(time.Now()) {
+			jsonResponse(c, http.StatusConflict, "订单已超过支付时间", nil)
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "用户不存在", nil)
+			return
+		}
+
+		description := "鲜花订单 " + order.OrderNo
+		if len(order.Items) > 0 {
+			description = order.Items[0].Name
+		}
+		req := payment.PrepayRequest{
+			OrderNo:     order.OrderNo,
+			Description: description,
+			Amount:      toFen(order.TotalAmount),
+			OpenID:      user.OpenID,
+		}
+		if order.ExpiresAt != nil {
+			req.ExpiresAt = *order.ExpiresAt
+		}
+
+		// 先写入支付记录，回调可能比 CreatePrepay 返回得更早
+		record := models.Payment{
+			OrderID:  order.ID,
+			OrderNo:  order.OrderNo,
+			Provider: pay.Name(),
+			Amount:   order.TotalAmount,
+			Status:   models.PaymentStatusPending,
+		}
+		if err := db.Where("order_id = ?", order.ID).Attrs(record).FirstOrCreate(&record).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "发起支付失败", nil)
+			return
+		}
+
+		result, err := pay.CreatePrepay(c.Request.Context(), req)
+		if err != nil {
+			log.Printf("create prepay for %s failed: %v", order.OrderNo, err)
+			jsonResponse(c, http.StatusBadGateway, "发起支付失败，请稍后重试", nil)
+			return
+		}
+		db.Model(&record).Update("prepay_id", result.PrepayID)
+
+		jsonResponse(c, http.StatusOK, "获取成功", gin.H{
+			"order_no": order.OrderNo,
+			"pay":      result.Params,
+		})
+	}
+}
+
+// 主动查询支付结果，用于回调延迟时前端轮询
+func orderPaySyncHandler(db *gorm.DB, pay payment.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var order models.Order
+		if err := db.Where("id = ? AND user_id = ?", c.Param("id"), c.MustGet("userID").(uint)).First(&order).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "订单不存在", nil)
+			return
+		}
+
+		if order.Status == models.OrderStatusPendingPayment {
+			tx, err := pay.Query(c.Request.Context(), order.OrderNo)
+			if err != nil {
+				log.Printf("query payment %s failed: %v", order.OrderNo, err)
+				jsonResponse(c, http.StatusBadGateway, "查询支付结果失败", nil)
+				return
+			}
+			if err := applyTransaction(db, pay, tx); err != nil {
+				log.Printf("apply payment %s failed: %v", order.OrderNo, err)
+			}
+			db.First(&order, order.ID)
+		}
+
+		jsonResponse(c, http.StatusOK, "获取成功", gin.H{
+			"order_no": order.OrderNo,
+			"status":   order.Status,
+			"paid":     order.PaidAt != nil,
+		})
+	}
+}
+
+// 支付结果回调，验签后按订单号幂等地更新订单
+func paymentNotifyHandler(db *gorm.DB, pay payment.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tx, err := pay.VerifyNotify(c.Request)
+		if err != nil {
+			log.Printf("verify payment notify failed: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"code": "FAIL", "message": "签名校验失败"})
+			return
+		}
+		if err := applyTransaction(db, pay, tx); err != nil {
+			log.Printf("apply payment notify %s failed: %v", tx.OrderNo, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"code": "FAIL", "message": "处理失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"code": "SUCCESS", "message": "成功"})
+	}
+}
+
+// applyTransaction 根据渠道返回的交易结果更新支付记录和订单，重复调用结果不变
+// 订单已因超时取消后才收到支付成功的，自动原路退款
+func applyTransaction(db *gorm.DB, pay payment.Provider, t *payment.Transaction) error {
+	var order models.Order
+	if err := db.Where("order_no = ?", t.OrderNo).First(&order).Error; err != nil {
+		return err
+	}
+
+	switch t.State {
+	case payment.StateSuccess:
+	case payment.StatePayError, payment.StateClosed:
+		return db.Model(&models.Payment{}).
+			Where("order_id = ? AND status = ?", order.ID, models.PaymentStatusPending).
+			Update("status", models.PaymentStatusFailed).Error
+	default:
+		return nil // 未支付等中间状态无需处理
+	}
+
+	if t.Amount != toFen(order.TotalAmount) {
+		return errAmountMismatch
+	}
+
+	needRefund := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var record models.Payment
+		err := tx.Where("order_id = ?", order.ID).First(&record).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			record = models.Payment{
+				OrderID:  order.ID,
+				OrderNo:  order.OrderNo,
+				Provider: pay.Name(),
+				Amount:   order.TotalAmount,
+			}
+		} else if err != nil {
+			return err
+		}
+		if record.Status == models.PaymentStatusSuccess || record.Status == models.PaymentStatusRefunding ||
+			record.Status == models.PaymentStatusRefunded || record.Status == models.PaymentStatusRefundFailed {
+			return nil // 重复回调
+		}
+
+		paidAt := t.PaidAt
+		if paidAt.IsZero() {
+			paidAt = time.Now()
+		}
+		record.Status = models.PaymentStatusSuccess
+		record.TransactionID = t.TransactionID
+		record.PaidAt = &paidAt
+		if err := tx.Save(&record).Error; err != nil {
+			return err
+		}
+
+		if order.Status == models.OrderStatusPendingPayment {
+			err := transitionOrder(tx, &order, models.OrderStatusPaid, operatorSystem, 0, "支付成功 "+t.TransactionID)
+			if err != errIllegalTransition {
+				return err
+			}
+			// 与超时取消并发，重新读取订单状态
+			if err := tx.First(&order, order.ID).Error; err != nil {
+				return err
+			}
+		}
+		needRefund = order.Status == models.OrderStatusCancelled
+		return nil
+	})
+	if err != nil || !needRefund {
+		return err
+	}
+
+	log.Printf("order %s paid after cancellation, refunding", order.OrderNo)
+	_, _, err = refundPayment(context.Background(), db, pay, order.ID, "订单已取消，自动退款", operatorSystem, 0)
+	return err
+}
+
+// refundPayment 退款分三步：先锁住订单并把支付记录条件更新为退款中，同一订单只有一个请求能继续；
+// 再调用渠道退款；渠道受理后支付记录和订单状态在同一事务中保存。渠道返回处理中时支付记录保持退款中，
+// 由 syncRefunds 查询最终结果；渠道未受理时恢复为支付成功以便重试。已取消订单的自动退款不修改订单状态
+func refundPayment(ctx context.Context, db *gorm.DB, pay payment.Provider, orderID uint, reason, operatorType string, operatorID uint) (models.Order, *payment.RefundResult, error) {
+	var order models.Order
+	var record models.Payment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return err
+		}
+		if order.Status != models.OrderStatusCancelled && !models.CanTransitionOrder(order.Status, models.OrderStatusRefunded) {
+			return errIllegalTransition
+		}
+		if err := tx.Where("order_id = ?", order.ID).First(&record).Error; err != nil {
+			return err
+		}
+		record.RefundNo = "R" + order.OrderNo
+		result := tx.Model(&record).Where("status = ?", models.PaymentStatusSuccess).Updates(map[string]interface{}{
+			"status":    models.PaymentStatusRefunding,
+			"refund_no": record.RefundNo,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefundConflict
+		}
+		return nil
+	})
+	if err != nil {
+		return order, nil, err
+	}
+
+	result, err := pay.Refund(ctx, payment.RefundRequest{
+		OrderNo:  order.OrderNo,
+		RefundNo: record.RefundNo,
+		Amount:   toFen(record.Amount),
+		Total:    toFen(record.Amount),
+		Reason:   reason,
+	})
+	if err == nil && result.Status != payment.RefundSuccess && result.Status != payment.RefundProcessing {
+		err = fmt.Errorf("refund %s: %s", record.RefundNo, result.Status)
+	}
+	if err != nil {
+		if rerr := db.Model(&record).Where("status = ?", models.PaymentStatusRefunding).
+			Update("status", models.PaymentStatusSuccess).Error; rerr != nil {
+			log.Printf("release refund of order %s failed: %v", order.OrderNo, rerr)
+		}
+		return order, nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"refund_id": result.RefundID}
+		if result.Status == payment.RefundSuccess {
+			updates["status"] = models.PaymentStatusRefunded
+			updates["refunded_at"] = time.Now()
+		}
+		if err := tx.Model(&record).Updates(updates).Error; err != nil {
+			return err
+		}
+		if order.Status == models.OrderStatusCancelled {
+			return nil
+		}
+		err := transitionOrder(tx, &order, models.OrderStatusRefunded, operatorType, operatorID, reason)
+		if err != errIllegalTransition {
+			return err
+		}
+		// 退款期间订单状态被推进，按最新状态重新流转；已无法流转时仍保存退款结果
+		if err := tx.First(&order, order.ID).Error; err != nil {
+			return err
+		}
+		if err := transitionOrder(tx, &order, models.OrderStatusRefunded, operatorType, operatorID, reason); err != errIllegalTransition {
+			return err
+		}
+		log.Printf("order %s refunded in status %s", order.OrderNo, order.Status)
+		return nil
+	})
+	return order, result, err
+}
+
+// 管理端订单退款
+func adminRefundOrderHandler(db *gorm.DB, pay payment.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Reason string `json:"reason" binding:"required,max=80"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "请填写退款原因", nil)
+			return
+		}
+
+		var order models.Order
+		if err := db.First(&order, c.Param("id")).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "订单不存在", nil)
+			return
+		}
+		// 已取消的订单由支付回调自动退款
+		if order.Status == models.OrderStatusCancelled {
+			jsonResponse(c, http.StatusConflict, errIllegalTransition.Error(), nil)
+			return
+		}
+
+		adminID := c.MustGet("adminID").(uint)
+		order, result, err := refundPayment(c.Request.Context(), db, pay, order.ID, req.Reason, operatorAdmin, adminID)
+		switch {
+		case err == errIllegalTransition || err == errRefundConflict:
+			jsonResponse(c, http.StatusConflict, err.Error(), nil)
+			return
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			jsonResponse(c, http.StatusConflict, "订单没有支付记录", nil)
+			return
+		case err != nil && result == nil:
+			log.Printf("refund order %s failed: %v", order.OrderNo, err)
+			jsonResponse(c, http.StatusBadGateway, "退款失败", nil)
+			return
+		case err != nil:
+			log.Printf("save refund of order %s failed: %v", order.OrderNo, err)
+			jsonResponse(c, http.StatusInternalServerError, "退款已提交，保存退款结果失败", nil)
+			return
+		}
+		if result.Status == payment.RefundProcessing {
+			jsonResponse(c, http.StatusOK, "退款处理中", order)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "退款成功", order)
+	}
+}
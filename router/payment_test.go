@@ -0,0 +1,227 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/payment"
+	"github.com/gin-gonic/gin"
+)
+
+// pendingOrder 买家下单，返回待支付订单和买家 token
+func (s *testServer) pendingOrder() (models.Order, string) {
+	s.t.Helper()
+	merchant, merchantToken := s.merchant("shop")
+	slotID, date := s.deliverySlot(merchantToken, 10)
+	flower := s.flower(merchant.ID, "红玫瑰", 19.9, 5)
+	token := s.user("buyer")
+	addressID := s.address(token)
+	expect(s.t, s.call(http.MethodPost, "/api/public/v1/my/cart", token, gin.H{"flower_id": flower.ID, "quantity": 2}), http.StatusOK)
+
+	resp := s.call(http.MethodPost, "/api/public/v1/my/orders", token, gin.H{
+		"address_id": addressID, "delivery_date": date, "delivery_slot_id": slotID,
+	})
+	expect(s.t, resp, http.StatusCreated)
+	var order models.Order
+	resp.decode(s.t, &order)
+	return order, token
+}
+
+// payNotify 按模拟渠道的报文格式签名并投递支付回调，返回 HTTP 状态码
+// 回调应答不是 jsonResponse 格式，不经过 serve 解析
+func (s *testServer) payNotify(orderNo, state string, amount int64, secret string) int {
+	s.t.Helper()
+	body, err := json.Marshal(gin.H{
+		"order_no": orderNo, "transaction_id": "mock_tx_" + orderNo, "state": state, "amount": amount, "paid_at": time.Now().Unix(),
+	})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/public/v1/pay/notify", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Mock-Signature", hex.EncodeToString(mac.Sum(nil)))
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w.Code
+}
+
+// payment 订单的支付记录
+func (s *testServer) payment(orderID uint) models.Payment {
+	s.t.Helper()
+	var record models.Payment
+	if err := s.db.Where("order_id = ?", orderID).First(&record).Error; err != nil {
+		s.t.Fatalf("payment of order %d: %v", orderID, err)
+	}
+	return record
+}
+
+func TestOrderPayNotify(t *testing.T) {
+	s := newTestServer(t)
+	order, token := s.pendingOrder()
+	path := fmt.Sprintf("/api/public/v1/my/orders/%d/pay", order.ID)
+
+	resp := s.call(http.MethodPost, path, token, nil)
+	expect(t, resp, http.StatusOK)
+	var prepay struct {
+		OrderNo string            `json:"order_no"`
+		Pay     map[string]string `json:"pay"`
+	}
+	resp.decode(t, &prepay)
+	if prepay.OrderNo != order.OrderNo || prepay.Pay["package"] != "prepay_id=mock_"+order.OrderNo {
+		t.Fatalf("prepay = %+v", prepay)
+	}
+	if record := s.payment(order.ID); record.Status != models.PaymentStatusPending || record.PrepayID != "mock_"+order.OrderNo {
+		t.Fatalf("payment = %+v", record)
+	}
+
+	amount := toFen(order.TotalAmount)
+	if code := s.payNotify(order.OrderNo, payment.StateSuccess, amount, "wrong-secret"); code != http.StatusUnauthorized {
+		t.Fatalf("notify with bad signature = %d, want 401", code)
+	}
+	if code := s.payNotify(order.OrderNo, payment.StateSuccess, amount-1, testPaySecret); code != http.StatusInternalServerError {
+		t.Fatalf("notify with wrong amount = %d, want 500", code)
+	}
+	var got models.Order
+	s.db.First(&got, order.ID)
+	if got.Status != models.OrderStatusPendingPayment {
+		t.Fatalf("order status = %s after rejected notifies", got.Status)
+	}
+
+	// 渠道会重复投递回调，订单只流转一次
+	for i := 0; i < 2; i++ {
+		if code := s.payNotify(order.OrderNo, payment.StateSuccess, amount, testPaySecret); code != http.StatusOK {
+			t.Fatalf("notify %d = %d, want 200", i, code)
+		}
+	}
+	s.db.First(&got, order.ID)
+	if got.Status != models.OrderStatusPaid || got.PaidAt == nil {
+		t.Fatalf("order = %+v after notify", got)
+	}
+	if record := s.payment(order.ID); record.Status != models.PaymentStatusSuccess || record.TransactionID != "mock_tx_"+order.OrderNo {
+		t.Fatalf("payment = %+v", record)
+	}
+	var paid int64
+	s.db.Model(&models.OrderStatusHistory{}).Where("order_id = ? AND to_status = ?", order.ID, models.OrderStatusPaid).Count(&paid)
+	if paid != 1 {
+		t.Fatalf("paid history = %d, want 1", paid)
+	}
+
+	expect(t, s.call(http.MethodPost, path, token, nil), http.StatusConflict)
+}
+
+func TestOrderPaySync(t *testing.T) {
+	s := newTestServer(t)
+	order, token := s.pendingOrder()
+	expect(t, s.call(http.MethodPost, fmt.Sprintf("/api/public/v1/my/orders/%d/pay", order.ID), token, nil), http.StatusOK)
+	path := fmt.Sprintf("/api/public/v1/my/orders/%d/pay/sync", order.ID)
+
+	var sync struct {
+		Status string `json:"status"`
+		Paid   bool   `json:"paid"`
+	}
+	resp := s.call(http.MethodPost, path, token, nil)
+	expect(t, resp, http.StatusOK)
+	resp.decode(t, &sync)
+	if sync.Status != models.OrderStatusPendingPayment || sync.Paid {
+		t.Fatalf("sync before payment = %+v", sync)
+	}
+
+	// 没有收到回调时通过查询得到支付结果
+	if err := s.pay.Complete(order.OrderNo, payment.StateSuccess); err != nil {
+		t.Fatal(err)
+	}
+	resp = s.call(http.MethodPost, path, token, nil)
+	expect(t, resp, http.StatusOK)
+	resp.decode(t, &sync)
+	if sync.Status != models.OrderStatusPaid || !sync.Paid {
+		t.Fatalf("sync after payment = %+v", sync)
+	}
+}
+
+func TestAdminRefundOrder(t *testing.T) {
+	s := newTestServer(t)
+	order, token := s.pendingOrder()
+	expect(t, s.call(http.MethodPost, fmt.Sprintf("/api/public/v1/my/orders/%d/pay", order.ID), token, nil), http.StatusOK)
+	if err := s.pay.Complete(order.OrderNo, payment.StateSuccess); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, s.call(http.MethodPost, fmt.Sprintf("/api/public/v1/my/orders/%d/pay/sync", order.ID), token, nil), http.StatusOK)
+
+	admin := s.adminToken()
+	path := fmt.Sprintf("/api/public/v1/admin/orders/%d/refund", order.ID)
+	expect(t, s.call(http.MethodPost, path, admin, gin.H{}), http.StatusBadRequest)
+
+	resp := s.call(http.MethodPost, path, admin, gin.H{"reason": "缺货"})
+	expect(t, resp, http.StatusOK)
+	var got models.Order
+	resp.decode(t, &got)
+	if got.Status != models.OrderStatusRefunded {
+		t.Fatalf("order = %+v after refund", got)
+	}
+	record := s.payment(order.ID)
+	if record.Status != models.PaymentStatusRefunded || record.RefundNo != "R"+order.OrderNo ||
+		record.RefundID != "mock_refund_R"+order.OrderNo || record.RefundedAt == nil {
+		t.Fatalf("payment = %+v after refund", record)
+	}
+
+	expect(t, s.call(http.MethodPost, path, admin, gin.H{"reason": "缺货"}), http.StatusConflict)
+}
+
+func TestSyncRefunds(t *testing.T) {
+	s := newTestServer(t)
+	// 渠道受理退款后异步处理，退款结果只能通过查询得到
+	pay := payment.NewMock(payment.MockConfig{Mode: payment.MockModeManual, Secret: testPaySecret, Refund: payment.MockRefundProcessing})
+	ctx := context.Background()
+
+	refund := func(orderNo string) models.Order {
+		order := models.Order{OrderNo: orderNo, UserID: 1, Status: models.OrderStatusPaid, TotalAmount: 10}
+		s.db.Create(&order)
+		s.db.Create(&models.Payment{OrderID: order.ID, OrderNo: orderNo, Provider: pay.Name(), Amount: 10, Status: models.PaymentStatusSuccess})
+		order, result, err := refundPayment(ctx, s.db, pay, order.ID, "缺货", operatorAdmin, 1)
+		if err != nil {
+			t.Fatalf("refund %s: %v", orderNo, err)
+		}
+		if result.Status != payment.RefundProcessing || order.Status != models.OrderStatusRefunded {
+			t.Fatalf("refund %s = %+v, order status %s", orderNo, result, order.Status)
+		}
+		return order
+	}
+	done := refund("P1")
+	closed := refund("P2")
+
+	if n, err := syncRefunds(ctx, s.db, pay); err != nil || n != 0 {
+		t.Fatalf("syncRefunds while processing = %d, %v", n, err)
+	}
+	if record := s.payment(done.ID); record.Status != models.PaymentStatusRefunding {
+		t.Fatalf("payment = %+v while processing", record)
+	}
+
+	pay.CompleteRefund("RP1", payment.RefundSuccess)
+	pay.CompleteRefund("RP2", payment.RefundClosed)
+	if n, err := syncRefunds(ctx, s.db, pay); err != nil || n != 2 {
+		t.Fatalf("syncRefunds = %d, %v; want 2", n, err)
+	}
+	if record := s.payment(done.ID); record.Status != models.PaymentStatusRefunded || record.RefundedAt == nil {
+		t.Fatalf("payment = %+v after refund succeeded", record)
+	}
+	if record := s.payment(closed.ID); record.Status != models.PaymentStatusRefundFailed || record.RefundedAt != nil {
+		t.Fatalf("payment = %+v after refund closed", record)
+	}
+
+	// 已有结果的退款不再查询
+	if n, err := syncRefunds(ctx, s.db, pay); err != nil || n != 0 {
+		t.Fatalf("syncRefunds after completion = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,62 @@
+package router
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/payment"
+	"gorm.io/gorm"
+)
+
+// StartRefundSyncWorker 定时查询渠道处理中的退款，更新支付记录的退款结果
+func StartRefundSyncWorker(db *gorm.DB, pay payment.Provider, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if n, err := syncRefunds(context.Background(), db, pay); err != nil {
+				log.Printf("sync refunds failed: %v", err)
+			} else if n > 0 {
+				log.Printf("synced %d refunds", n)
+			}
+		}
+	}()
+}
+
+// syncRefunds 查询已被渠道受理但仍在退款中的支付记录，返回有最终结果的数量
+// 没有 refund_id 的记录还在 refundPayment 中等待渠道受理，不在这里处理
+func syncRefunds(ctx context.Context, db *gorm.DB, pay payment.Provider) (int, error) {
+	var records []models.Payment
+	if err := db.Where("status = ? AND refund_id <> ''", models.PaymentStatusRefunding).
+		Order("id").Limit(100).Find(&records).Error; err != nil {
+		return 0, err
+	}
+
+	synced := 0
+	for _, record := range records {
+		result, err := pay.QueryRefund(ctx, record.RefundNo)
+		if err != nil {
+			log.Printf("query refund %s failed: %v", record.RefundNo, err)
+			continue
+		}
+
+		updates := map[string]interface{}{}
+		switch result.Status {
+		case payment.RefundSuccess:
+			updates["status"] = models.PaymentStatusRefunded
+			updates["refunded_at"] = time.Now()
+		case payment.RefundClosed, payment.RefundAbnormal:
+			log.Printf("refund %s of order %s is %s, handle it on the merchant platform", record.RefundNo, record.OrderNo, result.Status)
+			updates["status"] = models.PaymentStatusRefundFailed
+		default:
+			continue // 仍在处理中
+		}
+		if err := db.Model(&record).Where("status = ?", models.PaymentStatusRefunding).Updates(updates).Error; err != nil {
+			return synced, err
+		}
+		synced++
+	}
+	return synced, nil
+}
@@ -0,0 +1,176 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/storage"
+	"github.com/disintegration/imaging"
+	"github.com/gen2brain/webp"
+	"github.com/gin-gonic/gin"
+	_ "golang.org/x/image/webp" // 注册 webp 解码
+)
+
+// --------------------------------------图片多尺寸生成
+// 上传的图片按商品图片的规格生成 800/400/200 三种尺寸(对应 pics_big/pics_mid/pics_sma)，
+// 每种尺寸另存一份 webp；原图按 EXIF 方向摆正后重新编码，去掉 EXIF 等元数据
+
+// maxImagePixels 允许上传的最大像素数，防止解码超大图片耗尽内存
+const maxImagePixels = 40_000_000
+
+// renditionJPEGQuality 生成图片的 JPEG 质量
+const renditionJPEGQuality = 85
+
+// renditionWebPQuality 生成图片的 webp 质量，使用有损压缩，体积明显小于同尺寸的 JPEG
+const renditionWebPQuality = 80
+
+// imageRenditions 生成的尺寸，按从大到小排列
+var imageRenditions = []struct {
+	Size int
+	Name string
+}{
+	{800, "big"},
+	{400, "mid"},
+	{200, "sma"},
+}
+
+// renderedFile 待写入存储的文件
+type renderedFile struct {
+	Key         string
+	ContentType string
+	Data        []byte
+}
+
+// renderImage 生成原图和各尺寸图片，base 为不带扩展名的 key
+func renderImage(data []byte, format imageFormat, base string) (models.FlowerImage, []renderedFile, error) {
+	var img models.FlowerImage
+	src, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return img, nil, err
+	}
+
+	// GIF 没有 EXIF，保留原文件以免丢失动画
+	original := renderedFile{Key: base + format.Ext, ContentType: format.ContentType, Data: data}
+	if format.ContentType != "image/gif" {
+		if original.Data, err = encodeImage(src, format.ContentType); err != nil {
+			return img, nil, err
+		}
+	}
+	img.Path = original.Key
+	files := []renderedFile{original}
+
+	for _, r := range imageRenditions {
+		resized := imaging.Fit(src, r.Size, r.Size, imaging.Lanczos)
+		contentType, ext := "image/jpeg", ".jpg"
+		if !resized.Opaque() {
+			contentType, ext = "image/png", ".png"
+		}
+		encoded, err := encodeImage(resized, contentType)
+		if err != nil {
+			return img, nil, err
+		}
+		webp, err := encodeImage(resized, "image/webp")
+		if err != nil {
+			return img, nil, err
+		}
+		name := fmt.Sprintf("%s_%d", base, r.Size)
+		files = append(files,
+			renderedFile{Key: name + ext, ContentType: contentType, Data: encoded},
+			renderedFile{Key: name + ".webp", ContentType: "image/webp", Data: webp},
+		)
+		img.SetRendition(r.Name, name+ext, name+".webp")
+	}
+	return img, files, nil
+}
+
+// encodeImage 按格式编码，JPEG 和 webp 使用有损压缩
+func encodeImage(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		err = imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(renditionJPEGQuality))
+	case "image/png":
+		err = imaging.Encode(&buf, img, imaging.PNG)
+	case "image/webp":
+		err = webp.Encode(&buf, img, webp.Options{Quality: renditionWebPQuality, Method: webp.DefaultMethod})
+	default:
+		err = fmt.Errorf("unsupported image type %s", contentType)
+	}
+	return buf.Bytes(), err
+}
+
+// storeImage 生成各尺寸图片并写入存储，返回记录了全部 key 的图片(未关联鲜花)；
+// 写入失败时删除已写入的文件
+func storeImage(c *gin.Context, store storage.Storage, upload uploadedImage) (models.FlowerImage, error) {
+	f, err := upload.File.Open()
+	if err != nil {
+		return models.FlowerImage{}, err
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return models.FlowerImage{}, err
+	}
+
+	key, err := newImageKey("")
+	if err != nil {
+		return models.FlowerImage{}, err
+	}
+	img, files, err := renderImage(data, upload.Format, key)
+	if err != nil {
+		return models.FlowerImage{}, err
+	}
+
+	for i, file := range files {
+		if err := store.Put(c.Request.Context(), file.Key, bytes.NewReader(file.Data), int64(len(file.Data)), file.ContentType); err != nil {
+			for _, done := range files[:i] {
+				deleteStoredFile(context.Background(), store, done.Key)
+			}
+			return models.FlowerImage{}, err
+		}
+	}
+	return img, nil
+}
+
+// stageImages 在写数据库之前先写入全部图片文件，Sort 从 first 开始按上传顺序递增；
+// 任意一张失败时删除已写入的文件。数据库事务失败时调用方需用 discardImages 删除返回的图片
+func stageImages(c *gin.Context, store storage.Storage, uploads []uploadedImage, first int) ([]models.FlowerImage, error) {
+	staged := make([]models.FlowerImage, 0, len(uploads))
+	for i, upload := range uploads {
+		img, err := storeImage(c, store, upload)
+		if err != nil {
+			discardImages(store, staged)
+			return nil, err
+		}
+		img.Sort = first + i
+		staged = append(staged, img)
+	}
+	return staged, nil
+}
+
+// discardImages 删除图片文件，用于事务回滚或提交后清理旧图片，不受请求取消的影响
+func discardImages(store storage.Storage, imgs []models.FlowerImage) {
+	for _, img := range imgs {
+		deleteImageFiles(context.Background(), store, img)
+	}
+}
+
+// deleteImageFiles 删除图片的原图和全部尺寸，失败只记录日志
+func deleteImageFiles(ctx context.Context, store storage.Storage, img models.FlowerImage) {
+	for _, key := range img.Keys() {
+		deleteStoredFile(ctx, store, key)
+	}
+}
+
+// deleteStoredFile 删除存储中的文件，失败只记录日志
+func deleteStoredFile(ctx context.Context, store storage.Storage, key string) {
+	if err := store.Delete(ctx, key); err != nil {
+		log.Printf("delete stored file %s failed: %v", key, err)
+	}
+}
@@ -0,0 +1,27 @@
+package router
+
+import (
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/gin-gonic/gin"
+)
+
+// jsonResponse 按统一的 message/meta 格式返回
+func jsonResponse(c *gin.Context, status int, msg string, data interface{}) {
+	c.JSON(status, models.ApiResponse{
+		Message: data,
+		Meta: models.Meta{
+			Msg:    msg,
+			Status: status,
+		},
+	})
+}
+
+// abortResponse 按统一格式返回并终止后续处理，用于中间件
+func abortResponse(c *gin.Context, status int, msg string) {
+	c.AbortWithStatusJSON(status, models.ApiResponse{
+		Meta: models.Meta{
+			Msg:    msg,
+			Status: status,
+		},
+	})
+}
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"strings"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/sql"
+	"github.com/LookAt-MeNow/flowers/storage"
+)
+
+// --------------------------------------图片存储
+
+// flowerImagePrefix 鲜花图片在存储中的目录
+const flowerImagePrefix = "flowers/"
+
+// NewStorage 按配置创建图片存储，upload.baseURL 为相对路径时加上 server.publicURL，
+// 返回给前端和写入商品目录的都是完整地址
+func NewStorage(cfg *sql.Config) (storage.Storage, error) {
+	baseURL := cfg.Upload.BaseURL
+	if strings.HasPrefix(baseURL, "/") {
+		baseURL = strings.TrimRight(cfg.Server.PublicURL, "/") + baseURL
+	}
+	s3 := cfg.Upload.S3
+	return storage.New(storage.Config{
+		Driver:  cfg.Upload.Driver,
+		Dir:     cfg.Upload.Dir,
+		BaseURL: baseURL,
+		S3: storage.S3Config{
+			Endpoint:  s3.Endpoint,
+			AccessKey: s3.AccessKey,
+			SecretKey: s3.SecretKey,
+			Bucket:    s3.Bucket,
+			Region:    s3.Region,
+			UseSSL:    s3.UseSSL,
+		},
+	})
+}
+
+// resolveFlowerImages 根据图片 key 填充各尺寸的访问地址，已加载 SKU 时一起填充 SKU 的图片
+func resolveFlowerImages(store storage.Storage, flowers ...*models.Flower) {
+	for _, flower := range flowers {
+		for i := range flower.Images {
+			flower.Images[i].ResolveURLs(store.URL)
+		}
+		for i := range flower.SKUs {
+			if img, ok := skuImage(store, *flower, flower.SKUs[i]); ok {
+				flower.SKUs[i].Image = img.PicsMid
+			}
+		}
+	}
+}
+
+// skuImage SKU 的图片，已填充访问地址；SKU 没有设置图片或图片已删除时使用封面
+func skuImage(store storage.Storage, flower models.Flower, sku models.FlowerSKU) (models.FlowerImage, bool) {
+	for _, img := range flower.Images {
+		if sku.ImageID != 0 && img.ID == sku.ImageID {
+			img.ResolveURLs(store.URL)
+			return img, true
+		}
+	}
+	return flowerCover(store, flower)
+}
+
+// flowerCover 鲜花第一张图片，已填充访问地址；没有图片时 ok 为 false
+func flowerCover(store storage.Storage, flower models.Flower) (models.FlowerImage, bool) {
+	if len(flower.Images) == 0 {
+		return models.FlowerImage{}, false
+	}
+	cover := flower.Images[0]
+	cover.ResolveURLs(store.URL)
+	return cover, true
+}
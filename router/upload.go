@@ -0,0 +1,211 @@
+package router
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/LookAt-MeNow/flowers/sql"
+	"github.com/gin-gonic/gin"
+)
+
+// --------------------------------------图片上传校验
+
+// uploadField 表单中图片字段名
+const uploadField = "images"
+
+// uploadFormSlack 请求体上限之外为其他表单字段和 multipart 边界预留的空间
+const uploadFormSlack = 1 << 20
+
+var (
+	errUploadNotMultipart = errors.New("请使用 multipart/form-data 上传图片")
+	errUploadMalformed    = errors.New("表单解析失败")
+	errUploadTooLarge     = errors.New("上传内容超过大小限制")
+	errUploadTooMany      = errors.New("图片数量超过限制")
+)
+
+// uploadLimits 上传限制，来自 upload 配置
+type uploadLimits struct {
+	MaxFileSize    int64 // 单个文件(字节)
+	MaxRequestSize int64 // 一次请求的全部文件(字节)
+	MaxCount       int
+}
+
+// newUploadLimits 按配置生成上传限制
+func newUploadLimits(cfg *sql.Config) uploadLimits {
+	return uploadLimits{
+		MaxFileSize:    int64(cfg.Upload.MaxSize) << 20,
+		MaxRequestSize: int64(cfg.Upload.MaxRequestSize) << 20,
+		MaxCount:       cfg.Upload.MaxCount,
+	}
+}
+
+// imageFormat 允许上传的图片格式，按文件头识别，不信任扩展名和客户端声明的类型
+type imageFormat struct {
+	ContentType string
+	Ext         string
+	match       func(head []byte) bool
+}
+
+var imageFormats = []imageFormat{
+	{"image/jpeg", ".jpg", func(h []byte) bool { return bytes.HasPrefix(h, []byte{0xFF, 0xD8, 0xFF}) }},
+	{"image/png", ".png", func(h []byte) bool { return bytes.HasPrefix(h, []byte("\x89PNG\r\n\x1a\n")) }},
+	{"image/webp", ".webp", func(h []byte) bool {
+		return len(h) >= 12 && bytes.Equal(h[:4], []byte("RIFF")) && bytes.Equal(h[8:12], []byte("WEBP"))
+	}},
+	{"image/gif", ".gif", func(h []byte) bool {
+		return bytes.HasPrefix(h, []byte("GIF87a")) || bytes.HasPrefix(h, []byte("GIF89a"))
+	}},
+}
+
+// sniffImage 按文件头判断图片格式
+func sniffImage(head []byte) (imageFormat, bool) {
+	for _, f := range imageFormats {
+		if f.match(head) {
+			return f, true
+		}
+	}
+	return imageFormat{}, false
+}
+
+// uploadedImage 通过校验的图片
+type uploadedImage struct {
+	File   *multipart.FileHeader
+	Format imageFormat
+}
+
+// uploadFileError 单个文件的校验错误，Index 为文件在表单中的序号(从 0 开始)
+type uploadFileError struct {
+	Index    int    `json:"index"`
+	Filename string `json:"filename"`
+	Error    string `json:"error"`
+}
+
+// uploadFileErrors 全部未通过校验的文件
+type uploadFileErrors []uploadFileError
+
+func (e uploadFileErrors) Error() string {
+	return fmt.Sprintf("%d 个文件未通过校验", len(e))
+}
+
+// parseImageUploads 读取并校验表单中的图片，需在读取其他表单字段之前调用，
+// 否则请求体已被解析，大小限制不再生效；有文件未通过校验时返回 uploadFileErrors
+func parseImageUploads(c *gin.Context, limits uploadLimits) ([]uploadedImage, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.MaxRequestSize+uploadFormSlack)
+	form, err := c.MultipartForm()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, errUploadTooLarge
+		}
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil, errUploadNotMultipart
+		}
+		return nil, errUploadMalformed
+	}
+
+	files := form.File[uploadField]
+	if len(files) > limits.MaxCount {
+		return nil, errUploadTooMany
+	}
+	var (
+		images []uploadedImage
+		failed uploadFileErrors
+		total  int64
+	)
+	for i, file := range files {
+		total += file.Size
+		format, err := checkImageFile(file, limits)
+		if err != nil {
+			failed = append(failed, uploadFileError{Index: i, Filename: file.Filename, Error: err.Error()})
+			continue
+		}
+		images = append(images, uploadedImage{File: file, Format: format})
+	}
+	if len(failed) > 0 {
+		return nil, failed
+	}
+	if total > limits.MaxRequestSize {
+		return nil, errUploadTooLarge
+	}
+	return images, nil
+}
+
+// checkImageFile 校验单个文件的大小和格式
+func checkImageFile(file *multipart.FileHeader, limits uploadLimits) (imageFormat, error) {
+	if file.Size == 0 {
+		return imageFormat{}, errors.New("文件为空")
+	}
+	if file.Size > limits.MaxFileSize {
+		return imageFormat{}, fmt.Errorf("文件超过 %d MB", limits.MaxFileSize>>20)
+	}
+	f, err := file.Open()
+	if err != nil {
+		return imageFormat{}, errors.New("文件读取失败")
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return imageFormat{}, errors.New("文件读取失败")
+	}
+	format, ok := sniffImage(head[:n])
+	if !ok {
+		return imageFormat{}, errors.New("仅支持 JPEG、PNG、WebP、GIF 图片")
+	}
+	// 只读取图片头部的尺寸，拒绝损坏的图片和像素数过大的图片
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return imageFormat{}, errors.New("文件读取失败")
+	}
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return imageFormat{}, errors.New("图片已损坏")
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return imageFormat{}, fmt.Errorf("图片像素不能超过 %d 万", maxImagePixels/10000)
+	}
+	return format, nil
+}
+
+// uploadErrorStatus 上传错误对应的 HTTP 状态码
+func uploadErrorStatus(err error) int {
+	switch err {
+	case errUploadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case errUploadNotMultipart, errUploadMalformed, errUploadTooMany:
+		return http.StatusBadRequest
+	}
+	if _, ok := err.(uploadFileErrors); ok {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// respondUploadError 返回上传错误，逐个文件的错误放在 message.errors 中
+func respondUploadError(c *gin.Context, err error) {
+	if failed, ok := err.(uploadFileErrors); ok {
+		jsonResponse(c, http.StatusBadRequest, "图片校验失败", gin.H{"errors": failed})
+		return
+	}
+	status := uploadErrorStatus(err)
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		msg = "上传图片失败"
+	}
+	jsonResponse(c, status, msg, nil)
+}
+
+// newImageKey 生成图片在存储中的 key，文件名由服务端随机生成，不使用客户端的文件名
+func newImageKey(ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return flowerImagePrefix + hex.EncodeToString(b) + ext, nil
+}
@@ -0,0 +1,138 @@
+package router
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/LookAt-MeNow/flowers/identity"
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/sql"
+	"github.com/LookAt-MeNow/flowers/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// --------------------------------------买家端
+
+// 小程序登录：用 wx.login 的 code 换取 openid，首次登录自动注册
+func userLoginHandler(db *gorm.DB, cfg *sql.Config, idp identity.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Code     string `json:"code" binding:"required"`
+			Nickname string `json:"nickname" binding:"max=50"`
+			Avatar   string `json:"avatar" binding:"max=255"`
+			// 未登录时加入的游客购物车，登录后合并到账号购物车
+			GuestCart []cartLine `json:"guest_cart" binding:"max=100"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		session, err := idp.Code2Session(c.Request.Context(), req.Code)
+		if errors.Is(err, identity.ErrInvalidCode) {
+			jsonResponse(c, http.StatusUnauthorized, "登录凭证无效，请重新登录", nil)
+			return
+		}
+		if err != nil {
+			log.Printf("code2session failed: %v", err)
+			jsonResponse(c, http.StatusBadGateway, "微信登录失败，请稍后重试", nil)
+			return
+		}
+
+		// 按 openid 查找或创建买家
+		var user models.User
+		err = db.Where("open_id = ?", session.OpenID).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			jsonResponse(c, http.StatusInternalServerError, "登录失败", nil)
+			return
+		}
+		if user.ID == 0 {
+			user.Status = 1
+		}
+		user.OpenID = session.OpenID
+		user.SessionKey = session.SessionKey
+		if session.UnionID != "" {
+			user.UnionID = session.UnionID
+		}
+		if req.Nickname != "" {
+			user.Nickname = req.Nickname
+		}
+		if req.Avatar != "" {
+			user.Avatar = req.Avatar
+		}
+		if err := db.Save(&user).Error; err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "登录失败", nil)
+			return
+		}
+		if user.Status != 1 {
+			jsonResponse(c, http.StatusForbidden, "账号已被禁用", nil)
+			return
+		}
+
+		mergeGuestCart(db, user.ID, req.GuestCart)
+
+		token, err := utils.GenerateToken(cfg.Auth.JWTSecret, user.ID, utils.RoleUser, tokenTTL(cfg), 0)
+		if err != nil {
+			jsonResponse(c, http.StatusInternalServerError, "登录失败", nil)
+			return
+		}
+
+		jsonResponse(c, http.StatusOK, "登录成功", gin.H{
+			"token":      token,
+			"expires_in": int(tokenTTL(cfg).Seconds()),
+			"user":       user,
+		})
+	}
+}
+
+// 获取当前买家信息
+func userProfileHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user models.User
+		if err := db.First(&user, c.MustGet("userID").(uint)).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "用户不存在", nil)
+			return
+		}
+		jsonResponse(c, http.StatusOK, "获取成功", user)
+	}
+}
+
+// 修改昵称、头像、手机号
+func userUpdateProfileHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Nickname *string `json:"nickname" binding:"omitempty,max=50"`
+			Avatar   *string `json:"avatar" binding:"omitempty,max=255"`
+			Phone    *string `json:"phone" binding:"omitempty,max=20"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			jsonResponse(c, http.StatusBadRequest, "参数错误", nil)
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, c.MustGet("userID").(uint)).Error; err != nil {
+			jsonResponse(c, http.StatusNotFound, "用户不存在", nil)
+			return
+		}
+		updates := map[string]interface{}{}
+		if req.Nickname != nil {
+			updates["nickname"] = *req.Nickname
+		}
+		if req.Avatar != nil {
+			updates["avatar"] = *req.Avatar
+		}
+		if req.Phone != nil {
+			updates["phone"] = *req.Phone
+		}
+		if len(updates) > 0 {
+			if err := db.Model(&user).Updates(updates).Error; err != nil {
+				jsonResponse(c, http.StatusInternalServerError, "更新失败", nil)
+				return
+			}
+		}
+		jsonResponse(c, http.StatusOK, "更新成功", user)
+	}
+}
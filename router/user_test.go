@@ -0,0 +1,101 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/gin-gonic/gin"
+)
+
+// loginResult 买家登录的响应
+type loginResult struct {
+	Token     string      `json:"token"`
+	ExpiresIn int         `json:"expires_in"`
+	User      models.User `json:"user"`
+}
+
+func (s *testServer) userLogin(body gin.H) loginResult {
+	s.t.Helper()
+	resp := s.call(http.MethodPost, "/api/public/v1/auth/users/login", "", body)
+	expect(s.t, resp, http.StatusOK)
+	var out loginResult
+	resp.decode(s.t, &out)
+	return out
+}
+
+func TestUserLoginSameCodeSameUser(t *testing.T) {
+	s := newTestServer(t)
+
+	first := s.userLogin(gin.H{"code": "code-a", "nickname": "小明"})
+	if first.Token == "" || first.User.ID == 0 || first.ExpiresIn != 3600 {
+		t.Fatalf("login = %+v", first)
+	}
+	second := s.userLogin(gin.H{"code": "code-a"})
+	if second.User.ID != first.User.ID {
+		t.Fatalf("same code logged in as user %d, then %d", first.User.ID, second.User.ID)
+	}
+	// 未传昵称时保留已有资料
+	if second.User.Nickname != "小明" {
+		t.Fatalf("nickname = %q, want 小明", second.User.Nickname)
+	}
+	other := s.userLogin(gin.H{"code": "code-b"})
+	if other.User.ID == first.User.ID {
+		t.Fatal("different codes logged in as the same user")
+	}
+
+	var n int64
+	s.db.Model(&models.User{}).Count(&n)
+	if n != 2 {
+		t.Fatalf("users = %d, want 2", n)
+	}
+}
+
+func TestUserLoginInvalidCode(t *testing.T) {
+	s := newTestServer(t)
+
+	expect(t, s.call(http.MethodPost, "/api/public/v1/auth/users/login", "", gin.H{"code": "invalid"}), http.StatusUnauthorized)
+	expect(t, s.call(http.MethodPost, "/api/public/v1/auth/users/login", "", gin.H{}), http.StatusBadRequest)
+
+	var n int64
+	s.db.Model(&models.User{}).Count(&n)
+	if n != 0 {
+		t.Fatalf("users = %d after failed logins, want 0", n)
+	}
+}
+
+func TestUserTokenAccessesProfile(t *testing.T) {
+	s := newTestServer(t)
+	login := s.userLogin(gin.H{"code": "code-a", "nickname": "小明"})
+
+	resp := s.call(http.MethodGet, "/api/public/v1/my/profile", login.Token, nil)
+	expect(t, resp, http.StatusOK)
+	var user models.User
+	resp.decode(t, &user)
+	if user.ID != login.User.ID || user.Nickname != "小明" {
+		t.Fatalf("profile = %+v", user)
+	}
+
+	expect(t, s.call(http.MethodPut, "/api/public/v1/my/profile", login.Token, gin.H{"phone": "13800000000"}), http.StatusOK)
+	s.db.First(&user, login.User.ID)
+	if user.Phone != "13800000000" {
+		t.Fatalf("phone = %q after update", user.Phone)
+	}
+
+	// 被禁用的买家令牌立即失效，也不能重新登录
+	s.db.Model(&user).Update("status", 0)
+	expect(t, s.call(http.MethodGet, "/api/public/v1/my/profile", login.Token, nil), http.StatusForbidden)
+	expect(t, s.call(http.MethodPost, "/api/public/v1/auth/users/login", "", gin.H{"code": "code-a"}), http.StatusForbidden)
+}
+
+func TestUserLoginMergesGuestCart(t *testing.T) {
+	s := newTestServer(t)
+	merchant, _ := s.merchant("shop")
+	flower := s.flower(merchant.ID, "红玫瑰", 19.9, 5)
+
+	login := s.userLogin(gin.H{"code": "code-a", "guest_cart": []gin.H{{"flower_id": flower.ID, "quantity": 2}}})
+	items := s.cart(login.Token).Items
+	if len(items) != 1 || items[0].FlowerID != flower.ID || items[0].Quantity != 2 {
+		t.Fatalf("cart = %+v after login, want the guest line", items)
+	}
+}
@@ -0,0 +1,55 @@
+package sql
+
+import (
+	"errors"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/utils"
+	"gorm.io/gorm"
+)
+
+// ImportCategories 从 JSON 文件一次性导入分类树，保留原有 cat_id 以兼容商品的 cat_id
+// 分类表已有数据时不做任何修改
+func ImportCategories(db *gorm.DB, filePath string) (int, error) {
+	var tree []models.CategoryTree
+	if err := utils.LoadJSONData(filePath, &tree); err != nil {
+		return 0, err
+	}
+
+	// 层级按 JSON 中的嵌套关系计算；原数据中个别分类嵌套超过三级，挂到第二级分类下
+	var rows []models.CategoryTree
+	var flatten func(nodes []models.CategoryTree, ancestors []int)
+	flatten = func(nodes []models.CategoryTree, ancestors []int) {
+		for i, n := range nodes {
+			children := n.Children
+			n.Children = nil
+			n.CatLevel = len(ancestors)
+			n.CatPid = 0
+			if n.CatLevel > models.MaxCategoryLevel {
+				n.CatLevel = models.MaxCategoryLevel
+			}
+			if n.CatLevel > 0 {
+				n.CatPid = ancestors[n.CatLevel-1]
+			}
+			n.CatSort = i
+			rows = append(rows, n)
+			flatten(children, append(ancestors[:len(ancestors):len(ancestors)], n.CatID))
+		}
+	}
+	flatten(tree, nil)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.CategoryTree{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("categories table is not empty, import skipped")
+		}
+		return tx.CreateInBatches(rows, 200).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
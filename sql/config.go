@@ -0,0 +1,209 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix 环境变量前缀，配置项 mysql.password 对应 FLOWERS_MYSQL_PASSWORD
+const EnvPrefix = "FLOWERS"
+
+// configSearchPaths 未指定 --config 时依次查找 mysql.yml 的目录
+var configSearchPaths = []string{"./config", "/home/www/flowers/config"}
+
+// configDefaults 配置文件和环境变量都没有给出时使用的默认值
+var configDefaults = map[string]interface{}{
+	"database.driver":        "mysql",
+	"server.port":            8080,
+	"server.publicURL":       "http://127.0.0.1:8080",
+	"server.readTimeout":     15,
+	"server.writeTimeout":    30,
+	"server.idleTimeout":     60,
+	"server.shutdownTimeout": 10,
+	"upload.driver":          "local",
+	"upload.dir":             "uploads",
+	"upload.baseURL":         "/media",
+	"upload.maxSize":         5,
+	"upload.maxCount":        9,
+	"upload.maxRequestSize":  30,
+	"auth.tokenExpire":       24,
+	"wechat.provider":        "wechat",
+	"order.paymentTimeout":   30,
+}
+
+// LoadConfig 读取配置文件并用环境变量覆盖，path 为空时按 configSearchPaths 查找；
+// 找不到配置文件时只使用默认值和环境变量，校验失败时一次返回全部问题
+func LoadConfig(path string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.SetConfigName("mysql")
+		for _, dir := range configSearchPaths {
+			v.AddConfigPath(dir)
+		}
+	}
+	for key, value := range configDefaults {
+		v.SetDefault(key, value)
+	}
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	// Unmarshal 只会读取已知配置项的环境变量，配置文件中没有的项也要逐个绑定
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		if err := v.BindEnv(key); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if path != "" || !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+		log.Printf("no config file found in %s, using defaults and environment", strings.Join(configSearchPaths, ", "))
+	} else {
+		log.Printf("using config file %s", v.ConfigFileUsed())
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return &cfg, nil
+}
+
+// configKeys 列出结构体中全部叶子配置项，键名与 viper 的小写键名一致
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := prefix + strings.ToLower(f.Name)
+		if f.Type.Kind() == reflect.Struct {
+			keys = append(keys, configKeys(f.Type, key+".")...)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Validate 检查全部配置项，返回的错误包含所有缺失或不合法的值
+func (cfg *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	positive := func(key string, value int) {
+		if value <= 0 {
+			fail("%s must be greater than 0", key)
+		}
+	}
+
+	switch cfg.Database.Driver {
+	case "mysql":
+		if cfg.MySQL.Host == "" {
+			fail("mysql.host is required")
+		}
+		if cfg.MySQL.Port <= 0 || cfg.MySQL.Port > 65535 {
+			fail("mysql.port must be between 1 and 65535")
+		}
+		if cfg.MySQL.User == "" {
+			fail("mysql.user is required")
+		}
+		if cfg.MySQL.DBName == "" {
+			fail("mysql.dbname is required")
+		}
+	case "sqlite":
+		if cfg.Database.Path == "" {
+			fail("database.path is required when database.driver is sqlite")
+		}
+	default:
+		fail("database.driver must be mysql or sqlite")
+	}
+
+	if cfg.Server.Port <= 0 || cfg.Server.Port > 65535 {
+		fail("server.port must be between 1 and 65535")
+	}
+	if u, err := url.Parse(cfg.Server.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail("server.publicURL must be an absolute http or https URL")
+	}
+	positive("server.readTimeout", cfg.Server.ReadTimeout)
+	positive("server.writeTimeout", cfg.Server.WriteTimeout)
+	positive("server.idleTimeout", cfg.Server.IdleTimeout)
+	positive("server.shutdownTimeout", cfg.Server.ShutdownTimeout)
+
+	switch cfg.Upload.Driver {
+	case "local":
+		if cfg.Upload.Dir == "" {
+			fail("upload.dir is required when upload.driver is local")
+		}
+	case "s3":
+		if cfg.Upload.S3.Endpoint == "" || cfg.Upload.S3.Bucket == "" {
+			fail("upload.s3.endpoint and upload.s3.bucket are required when upload.driver is s3")
+		}
+		if cfg.Upload.S3.AccessKey == "" || cfg.Upload.S3.SecretKey == "" {
+			fail("upload.s3.accessKey and upload.s3.secretKey are required when upload.driver is s3")
+		}
+	default:
+		fail("upload.driver must be local or s3")
+	}
+	if u, err := url.Parse(cfg.Upload.BaseURL); err != nil || cfg.Upload.BaseURL == "" || (!strings.HasPrefix(cfg.Upload.BaseURL, "/") && u.Host == "") {
+		fail("upload.baseURL must be a path such as /media or an absolute URL")
+	}
+	positive("upload.maxSize", cfg.Upload.MaxSize)
+	positive("upload.maxCount", cfg.Upload.MaxCount)
+	if cfg.Upload.MaxRequestSize < cfg.Upload.MaxSize {
+		fail("upload.maxRequestSize must not be less than upload.maxSize")
+	}
+
+	if cfg.Auth.JWTSecret == "" {
+		fail("auth.jwtSecret is required")
+	}
+	positive("auth.tokenExpire", cfg.Auth.TokenExpire)
+
+	switch cfg.WeChat.Provider {
+	case "fake":
+	case "wechat":
+		if cfg.WeChat.AppID == "" || cfg.WeChat.AppSecret == "" {
+			fail("wechat.appID and wechat.appSecret are required unless wechat.provider is fake")
+		}
+	default:
+		fail("wechat.provider must be wechat or fake")
+	}
+
+	positive("order.paymentTimeout", cfg.Order.PaymentTimeout)
+
+	switch cfg.Payment.Provider {
+	case "mock":
+		if cfg.Payment.Mock.Secret == "" {
+			fail("payment.mock.secret is required")
+		}
+	case "wechat":
+		if cfg.Payment.WeChat.MchID == "" || cfg.Payment.WeChat.PrivateKeyPath == "" || cfg.Payment.WeChat.APIv3Key == "" {
+			fail("payment.wechat.mchID, privateKeyPath and apiV3Key are required")
+		}
+		if cfg.Payment.WeChat.PlatformPublicKeyPath == "" {
+			fail("payment.wechat.platformPublicKeyPath is required to verify wechat pay responses")
+		}
+		if cfg.WeChat.AppID == "" {
+			fail("wechat.appID is required when payment.provider is wechat")
+		}
+		if cfg.Payment.NotifyURL == "" {
+			fail("payment.notifyURL is required when payment.provider is wechat")
+		}
+	default:
+		fail("payment.provider must be wechat or mock")
+	}
+	return errors.Join(errs...)
+}
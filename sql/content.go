@@ -0,0 +1,67 @@
+package sql
+
+import (
+	"path/filepath"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"github.com/LookAt-MeNow/flowers/utils"
+	"gorm.io/gorm"
+)
+
+// ImportHomeContent 从 dir 下的 swiperdata.json、catitems.json、floordata.json 一次性导入首页内容
+// 按文件中的顺序排序并全部启用，已有数据的表跳过，返回导入的条数
+func ImportHomeContent(db *gorm.DB, dir string) (int, error) {
+	var banners []models.Banner
+	if err := utils.LoadJSONData(filepath.Join(dir, "swiperdata.json"), &banners); err != nil {
+		return 0, err
+	}
+	var navItems []models.Category
+	if err := utils.LoadJSONData(filepath.Join(dir, "catitems.json"), &navItems); err != nil {
+		return 0, err
+	}
+	var floors []models.Floor
+	if err := utils.LoadJSONData(filepath.Join(dir, "floordata.json"), &floors); err != nil {
+		return 0, err
+	}
+
+	for i := range banners {
+		banners[i].ContentSchedule = models.ContentSchedule{Sort: i, Enabled: true}
+	}
+	for i := range navItems {
+		navItems[i].ContentSchedule = models.ContentSchedule{Sort: i, Enabled: true}
+	}
+	for i := range floors {
+		floors[i].ContentSchedule = models.ContentSchedule{Sort: i, Enabled: true}
+		for j := range floors[i].ProductList {
+			floors[i].ProductList[j].Sort = j
+		}
+	}
+
+	imported := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		tables := []struct {
+			model interface{}
+			rows  interface{}
+			count int
+		}{
+			{&models.Banner{}, &banners, len(banners)},
+			{&models.Category{}, &navItems, len(navItems)},
+			{&models.Floor{}, &floors, len(floors)}, // 楼层商品随楼层一起创建
+		}
+		for _, t := range tables {
+			var existing int64
+			if err := tx.Model(t.model).Count(&existing).Error; err != nil {
+				return err
+			}
+			if existing > 0 || t.count == 0 {
+				continue
+			}
+			if err := tx.Create(t.rows).Error; err != nil {
+				return err
+			}
+			imported += t.count
+		}
+		return nil
+	})
+	return imported, err
+}
@@ -0,0 +1,330 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Migration 一个版本的表结构变更，已发布的版本不能再修改，新的变更追加新版本
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:100;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// 自定义表名
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// ErrSchemaBehind 数据库表结构落后于代码，需要先执行迁移
+var ErrSchemaBehind = errors.New("database schema is behind, run migrate first")
+
+// goodsSearchTable goods_search 表名与模型名不对应，按表名迁移
+const goodsSearchTable = "goods_search"
+
+// migrations 全部迁移，按版本号递增排列
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create tables",
+		// 已有手工建表的库执行时只补充缺少的列和索引
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(
+				&v1Admin{},
+				&v1Permission{},
+				&v1Role{},
+				&v1RolePermission{},
+				&v1Merchant{},
+				&v1MerchantStatusLog{},
+				&v1Flower{},
+				&v1FlowerImage{},
+				&v1Goods{},
+				&v1GoodsDetail{},
+				&v1GoodsPicture{},
+				&v1GoodsAttr{},
+				&v1Category{},
+				&v1Banner{},
+				&v1NavItem{},
+				&v1Floor{},
+				&v1FloorProduct{},
+				&v1User{},
+				&v1Address{},
+				&v1CartItem{},
+				&v1Order{},
+				&v1OrderItem{},
+				&v1OrderStatusHistory{},
+				&v1Payment{},
+				&v1DeliverySetting{},
+				&v1DeliverySlot{},
+				&v1DeliveryBlackout{},
+				&v1GoodsSearch{},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(
+				&v1DeliveryBlackout{},
+				&v1DeliverySlot{},
+				&v1DeliverySetting{},
+				&v1Payment{},
+				&v1OrderStatusHistory{},
+				&v1OrderItem{},
+				&v1Order{},
+				&v1CartItem{},
+				&v1Address{},
+				&v1User{},
+				&v1FloorProduct{},
+				&v1Floor{},
+				&v1NavItem{},
+				&v1Banner{},
+				&v1Category{},
+				&v1GoodsSearch{},
+				&v1GoodsAttr{},
+				&v1GoodsPicture{},
+				&v1GoodsDetail{},
+				&v1Goods{},
+				&v1FlowerImage{},
+				&v1Flower{},
+				&v1MerchantStatusLog{},
+				&v1Merchant{},
+				&v1RolePermission{},
+				&v1Role{},
+				&v1Permission{},
+				&v1Admin{},
+			)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "seed rbac",
+		Up:      SeedRBAC,
+		Down: func(tx *gorm.DB) error {
+			names := make([]string, 0, len(models.DefaultRoles))
+			for _, r := range models.DefaultRoles {
+				names = append(names, r.Name)
+			}
+			codes := make([]string, 0, len(models.DefaultPermissions))
+			for _, p := range models.DefaultPermissions {
+				codes = append(codes, p.Code)
+			}
+			if err := tx.Exec("DELETE FROM role_permissions WHERE role_id IN (SELECT id FROM roles WHERE name IN ?)", names).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("name IN ?", names).Delete(&models.Role{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("code IN ?", codes).Delete(&models.Permission{}).Error
+		},
+	},
+	{
+		Version: 3,
+		Name:    "flower image storage keys",
+		// 图片原来保存为相对工作目录的 uploads/xxx，改为存储中的 key
+		Up: func(tx *gorm.DB) error {
+			return tx.Model(&v1FlowerImage{}).Where("path LIKE ?", legacyUploadPrefix+"%").
+				UpdateColumn("path", gorm.Expr("SUBSTR(path, ?)", len(legacyUploadPrefix)+1)).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			expr := gorm.Expr("CONCAT(?, path)", legacyUploadPrefix)
+			if tx.Dialector.Name() == "sqlite" {
+				expr = gorm.Expr("? || path", legacyUploadPrefix)
+			}
+			// 只有旧版本写入的根目录文件名需要恢复前缀，新上传到 flowers/ 等目录下的 key 在旧版本中没有对应路径
+			return tx.Model(&v1FlowerImage{}).Where("path NOT LIKE ?", "%/%").UpdateColumn("path", expr).Error
+		},
+	},
+	{
+		Version: 4,
+		Name:    "flower image renditions",
+		// 旧图片没有多尺寸版本，返回时使用原图
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v4FlowerImage{})
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"big_path", "mid_path", "sma_path", "big_webp_path", "mid_webp_path", "sma_webp_path"} {
+				if err := tx.Migrator().DropColumn(&v4FlowerImage{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 5,
+		Name:    "flower image sort",
+		// 已有图片按上传顺序排列
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v5FlowerImage{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&v5FlowerImage{}, "sort")
+		},
+	},
+	{
+		Version: 6,
+		Name:    "flower skus",
+		// 已有鲜花没有规格，购物车和订单条目的 sku_id 为 0
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v6Flower{}, &v6FlowerOption{}, &v6FlowerSKU{}, &v6CartItem{}, &v6OrderItem{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&v6OrderItem{}, "sku_id"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropColumn(&v6CartItem{}, "sku_id"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&v6FlowerSKU{}, &v6FlowerOption{})
+		},
+	},
+	{
+		Version: 7,
+		Name:    "order fulfill permission",
+		// 平台商品订单由管理员备货、配送，已有的超级管理员和运营角色获得该权限
+		Up: func(tx *gorm.DB) error {
+			perm := v1Permission{Code: orderFulfillPermission, Name: "平台订单发货"}
+			if err := tx.Where("code = ?", perm.Code).FirstOrCreate(&perm).Error; err != nil {
+				return err
+			}
+			var roleIDs []uint
+			if err := tx.Model(&v1Role{}).Where("name IN ?", []string{"super_admin", "operator"}).Pluck("id", &roleIDs).Error; err != nil {
+				return err
+			}
+			for _, roleID := range roleIDs {
+				link := v1RolePermission{RoleID: roleID, PermissionID: perm.ID}
+				if err := tx.Omit(clause.Associations).Where(&link).FirstOrCreate(&link).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Exec("DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE code = ?)", orderFulfillPermission).Error; err != nil {
+				return err
+			}
+			return tx.Where("code = ?", orderFulfillPermission).Delete(&v1Permission{}).Error
+		},
+	},
+}
+
+// orderFulfillPermission 迁移 7 新增的权限编码
+const orderFulfillPermission = "order:fulfill"
+
+// legacyUploadPrefix 迁移 3 之前图片路径的前缀
+const legacyUploadPrefix = "uploads/"
+
+// LatestVersion 代码中最新的迁移版本
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion 数据库当前的迁移版本，未执行过迁移时为 0
+func SchemaVersion(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return 0, nil
+	}
+	var version int
+	err := db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// CheckSchema 表结构落后于代码时返回 ErrSchemaBehind，服务启动前调用
+func CheckSchema(db *gorm.DB) error {
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current < LatestVersion() {
+		return fmt.Errorf("%w (current %d, latest %d)", ErrSchemaBehind, current, LatestVersion())
+	}
+	return nil
+}
+
+// Migrate 按顺序执行所有未执行的迁移，返回本次执行的迁移
+func Migrate(db *gorm.DB) ([]Migration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// Rollback 按倒序回滚最近 steps 个已执行的迁移，返回本次回滚的迁移
+func Rollback(db *gorm.DB, steps int) ([]Migration, error) {
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var rolledBack []Migration
+	for i := len(migrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		m := migrations[i]
+		if m.Version > current {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return rolledBack, fmt.Errorf("rollback %d %s: %w", m.Version, m.Name, err)
+		}
+		rolledBack = append(rolledBack, m)
+	}
+	return rolledBack, nil
+}
+
+// MigrationStatus 列出全部迁移及其执行时间，未执行的 AppliedAt 为空
+func MigrationStatus(db *gorm.DB) ([]SchemaMigration, error) {
+	var done []SchemaMigration
+	if db.Migrator().HasTable(&SchemaMigration{}) {
+		if err := db.Find(&done).Error; err != nil {
+			return nil, err
+		}
+	}
+	appliedAt := make(map[int]time.Time, len(done))
+	for _, d := range done {
+		appliedAt[d.Version] = d.AppliedAt
+	}
+
+	status := make([]SchemaMigration, 0, len(migrations))
+	for _, m := range migrations {
+		status = append(status, SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: appliedAt[m.Version]})
+	}
+	return status, nil
+}
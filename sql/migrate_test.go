@@ -0,0 +1,98 @@
+package sql
+
+import (
+	"testing"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"gorm.io/gorm"
+)
+
+func newMigratedDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("NewMemoryDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// roleHasPermission 角色是否拥有指定权限
+func roleHasPermission(t *testing.T, db *gorm.DB, role, code string) bool {
+	t.Helper()
+	var n int64
+	err := db.Table("role_permissions").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("roles.name = ? AND permissions.code = ?", role, code).
+		Count(&n).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func TestMigrationOrderFulfillPermission(t *testing.T) {
+	db := newMigratedDB(t)
+	if !roleHasPermission(t, db, "operator", orderFulfillPermission) {
+		t.Fatal("operator lacks order:fulfill after a fresh migrate")
+	}
+
+	if _, err := Rollback(db, 1); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	var n int64
+	db.Table("permissions").Where("code = ?", orderFulfillPermission).Count(&n)
+	if n != 0 || roleHasPermission(t, db, "operator", orderFulfillPermission) {
+		t.Fatal("order:fulfill left behind after rollback")
+	}
+
+	// 已有数据库升级时补上权限并授予内置角色
+	if _, err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	for _, role := range []string{"super_admin", "operator"} {
+		if !roleHasPermission(t, db, role, orderFulfillPermission) {
+			t.Fatalf("%s lacks order:fulfill after upgrade", role)
+		}
+	}
+	if roleHasPermission(t, db, "auditor", orderFulfillPermission) {
+		t.Fatal("auditor granted order:fulfill")
+	}
+}
+
+func TestMigrationFlowerImageKeysRollback(t *testing.T) {
+	db := newMigratedDB(t)
+	merchant := models.Merchant{Username: "shop", Email: "shop@example.com"}
+	if err := db.Create(&merchant).Error; err != nil {
+		t.Fatal(err)
+	}
+	flower := models.Flower{MerchantID: merchant.ID, Name: "红玫瑰", CategoryID: 3}
+	if err := db.Create(&flower).Error; err != nil {
+		t.Fatal(err)
+	}
+	images := []models.FlowerImage{
+		{FlowerID: flower.ID, Path: "a.jpg"},
+		{FlowerID: flower.ID, Path: "flowers/1/b.jpg"},
+	}
+	if err := db.Create(&images).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 回滚到版本 2，只有根目录下的旧 key 恢复 uploads/ 前缀
+	if _, err := Rollback(db, LatestVersion()-2); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	want := map[uint]string{images[0].ID: "uploads/a.jpg", images[1].ID: "flowers/1/b.jpg"}
+	for id, path := range want {
+		var got string
+		db.Table("flower_images").Where("id = ?", id).Pluck("path", &got)
+		if got != path {
+			t.Fatalf("image %d path = %q, want %q", id, got, path)
+		}
+	}
+}
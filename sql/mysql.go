@@ -2,13 +2,16 @@ package sql
 
 import (
 	"log"
-	"github.com/spf13/viper"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"fmt"
 )
 
 type Config struct {
+	Database struct {
+		Driver string `yaml:"driver"` // mysql（默认）或 sqlite
+		Path   string `yaml:"path"`   // sqlite 数据库文件路径，:memory: 为内存库，每次启动都是空库
+	} `yaml:"database"` // 数据库驱动选择
 	MySQL struct {
 		Host        string `yaml:"host"`
 		Port        int    `yaml:"port"`
@@ -20,25 +23,76 @@ type Config struct {
 		MaxIdleConns int    `yaml:"maxIdleConns"`
 		MaxOpenConns int    `yaml:"maxOpenConns"`
 	} `yaml:"mysql"` // mysql 配置
+	Server struct {
+		Port            int    `yaml:"port"`            // 监听端口
+		PublicURL       string `yaml:"publicURL"`       // 对外访问地址，用于生成图片等资源的完整 URL
+		ReadTimeout     int    `yaml:"readTimeout"`     // 读取请求的超时时间(秒)
+		WriteTimeout    int    `yaml:"writeTimeout"`    // 写出响应的超时时间(秒)
+		IdleTimeout     int    `yaml:"idleTimeout"`     // 空闲连接保持时间(秒)
+		ShutdownTimeout int    `yaml:"shutdownTimeout"` // 退出时等待请求处理完成的时间(秒)
+	} `yaml:"server"` // HTTP 服务配置
+	Upload struct {
+		Driver         string `yaml:"driver"`         // local（默认）或 s3
+		Dir            string `yaml:"dir"`            // local：上传文件保存目录
+		BaseURL        string `yaml:"baseURL"`        // 图片访问地址前缀，使用 CDN 或公开读的 bucket 时填写其地址
+		MaxSize        int    `yaml:"maxSize"`        // 单个文件大小上限(MB)
+		MaxCount       int    `yaml:"maxCount"`       // 每次上传的文件数上限
+		MaxRequestSize int    `yaml:"maxRequestSize"` // 每次上传的文件总大小上限(MB)
+		S3             struct {
+			Endpoint  string `yaml:"endpoint"` // 不带协议，例如 127.0.0.1:9000
+			AccessKey string `yaml:"accessKey"`
+			SecretKey string `yaml:"secretKey"`
+			Bucket    string `yaml:"bucket"`
+			Region    string `yaml:"region"`
+			UseSSL    bool   `yaml:"useSSL"`
+		} `yaml:"s3"` // S3 兼容存储
+	} `yaml:"upload"` // 图片上传配置
+	Auth struct {
+		JWTSecret   string `yaml:"jwtSecret"`   // 令牌签名密钥
+		TokenExpire int    `yaml:"tokenExpire"` // 令牌有效期(小时)
+	} `yaml:"auth"` // 登录认证配置
+	WeChat struct {
+		AppID     string `yaml:"appID"`
+		AppSecret string `yaml:"appSecret"`
+		Provider  string `yaml:"provider"` // wechat 或 fake（本地模拟，不访问微信接口）
+	} `yaml:"wechat"` // 小程序登录配置
+	Order struct {
+		PaymentTimeout int `yaml:"paymentTimeout"` // 未支付订单保留库存的时间(分钟)
+	} `yaml:"order"` // 订单配置
+	Payment struct {
+		Provider  string `yaml:"provider"`  // wechat 或 mock
+		NotifyURL string `yaml:"notifyURL"` // 支付结果回调地址，对应 /api/public/v1/pay/notify
+		WeChat    struct {
+			MchID                 string `yaml:"mchID"`
+			SerialNo              string `yaml:"serialNo"`
+			PrivateKeyPath        string `yaml:"privateKeyPath"`
+			APIv3Key              string `yaml:"apiV3Key"`
+			PlatformPublicKeyPath string `yaml:"platformPublicKeyPath"`
+			PlatformSerial        string `yaml:"platformSerial"`
+		} `yaml:"wechat"`
+		Mock struct {
+			Mode   string `yaml:"mode"`   // success / fail / delay / manual
+			Delay  int    `yaml:"delay"`  // delay 模式下回调的延迟(秒)
+			Secret string `yaml:"secret"` // 回调签名密钥
+			Refund string `yaml:"refund"` // 退款结果 success / processing
+		} `yaml:"mock"`
+	} `yaml:"payment"` // 支付配置
 }
 
-func LoadConfig() *Config {
-	viper.SetConfigName("mysql") // 配置文件名称(无扩展名)
-	viper.SetConfigType("yaml")   // 如果配置文件的名称中没有扩展名，则需要配置此项
-	viper.AddConfigPath("/home/www/flowers/config") // 配置文件路径
-	
-	if err := viper.ReadInConfig(); err != nil { // 读取配置文件
-		log.Fatalf("Error reading config file: %v", err)
-	}
-	
-	var cfg Config // 定义配置结构体 用于保存配置文件中的数据 
-	if err := viper.Unmarshal(&cfg); err != nil {
-		log.Fatalf("Unable to decode config: %v", err)
-	}
-	return &cfg // 返回配置结构体指针
+// InMemory 是否使用 SQLite 内存库
+func (cfg *Config) InMemory() bool {
+	return cfg.Database.Driver == "sqlite" && cfg.Database.Path == ":memory:"
 }
 
 func InitDB(cfg *Config) *gorm.DB {
+	if cfg.Database.Driver == "sqlite" {
+		db, err := OpenSQLite(cfg.Database.Path)
+		if err != nil {
+			log.Fatalf("Failed to open sqlite database: %v", err)
+		}
+		return db
+	}
+
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=%t",
 		cfg.MySQL.User,
 		cfg.MySQL.Password,
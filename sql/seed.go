@@ -0,0 +1,45 @@
+package sql
+
+import (
+	"errors"
+
+	"github.com/LookAt-MeNow/flowers/models"
+	"gorm.io/gorm"
+)
+
+// SeedRBAC 写入内置权限和角色，已存在的记录不会被覆盖
+func SeedRBAC(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range models.DefaultPermissions {
+			perm := p
+			if err := tx.Where("code = ?", perm.Code).FirstOrCreate(&perm).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, r := range models.DefaultRoles {
+			var role models.Role
+			err := tx.Where("name = ?", r.Name).First(&role).Error
+			if err == nil {
+				continue // 已存在的角色保留管理员的修改
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			var perms []models.Permission
+			if err := tx.Where("code IN ?", r.Permissions).Find(&perms).Error; err != nil {
+				return err
+			}
+			role = models.Role{Name: r.Name, Description: r.Description, Permissions: perms}
+			if err := tx.Create(&role).Error; err != nil {
+				return err
+			}
+		}
+
+		// 旧数据中的 admin 角色从未做过权限限制，迁移为超级管理员
+		return tx.Model(&models.Admin{}).
+			Where("role = ? OR role = ''", "admin").
+			Update("role", models.RoleSuperAdmin).Error
+	})
+}
@@ -0,0 +1,464 @@
+package sql
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// --------------------------------------迁移使用的表结构快照
+// 已发布的迁移不能引用 models 中的模型：模型之后新增的字段会被旧版本的迁移提前建出来，
+// 新版本的迁移在新库上变成空操作，回滚时又删掉旧版本建的列。
+// 每个版本只使用自己的快照，快照发布后不再修改。
+
+// ---------------- 版本 1：初始表结构
+
+type v1Admin struct {
+	gorm.Model
+	Username string `gorm:"uniqueIndex;size:50;not null"`
+	Password string `gorm:"size:255;not null"`
+	Salt     string `gorm:"size:64"`
+	Role     string `gorm:"size:20;default:'operator'"`
+}
+
+func (v1Admin) TableName() string { return "admins" }
+
+type v1Permission struct {
+	ID   uint   `gorm:"primaryKey"`
+	Code string `gorm:"uniqueIndex;size:50;not null"`
+	Name string `gorm:"size:50;not null"`
+}
+
+func (v1Permission) TableName() string { return "permissions" }
+
+type v1Role struct {
+	gorm.Model
+	Name        string `gorm:"uniqueIndex;size:20;not null"`
+	Description string `gorm:"size:100"`
+}
+
+func (v1Role) TableName() string { return "roles" }
+
+// v1RolePermission 角色和权限的关联表，对应 models.Role 的 many2many:role_permissions
+type v1RolePermission struct {
+	RoleID       uint         `gorm:"primaryKey"`
+	PermissionID uint         `gorm:"primaryKey"`
+	Role         v1Role       `gorm:"foreignKey:RoleID"`
+	Permission   v1Permission `gorm:"foreignKey:PermissionID"`
+}
+
+func (v1RolePermission) TableName() string { return "role_permissions" }
+
+type v1Merchant struct {
+	gorm.Model
+	Username     string `gorm:"uniqueIndex;size:50;not null"`
+	Password     string `gorm:"size:255;not null"`
+	Salt         string `gorm:"size:64"`
+	ShopName     string `gorm:"size:100;not null"`
+	Email        string `gorm:"uniqueIndex;size:100;not null"`
+	Phone        string `gorm:"size:20;not null"`
+	Address      string `gorm:"size:255"`
+	Status       int    `gorm:"default:1"`
+	StatusReason string `gorm:"size:255"`
+	TokenVersion uint   `gorm:"default:0"`
+}
+
+func (v1Merchant) TableName() string { return "merchants" }
+
+type v1MerchantStatusLog struct {
+	gorm.Model
+	MerchantID uint   `gorm:"index;not null"`
+	AdminID    uint   `gorm:"index;not null"`
+	Action     string `gorm:"size:20;not null"`
+	FromStatus int
+	ToStatus   int
+	Reason     string `gorm:"size:255"`
+}
+
+func (v1MerchantStatusLog) TableName() string { return "merchant_status_logs" }
+
+type v1Flower struct {
+	gorm.Model
+	MerchantID  uint            `gorm:"index;not null"`
+	Name        string          `gorm:"size:100;not null"`
+	Price       float64         `gorm:"type:decimal(10,2);not null"`
+	Stock       int             `gorm:"not null"`
+	CategoryID  uint            `gorm:"index"`
+	Description string          `gorm:"type:text"`
+	Status      int             `gorm:"default:1"`
+	Images      []v1FlowerImage `gorm:"foreignKey:FlowerID"`
+}
+
+func (v1Flower) TableName() string { return "flowers" }
+
+type v1FlowerImage struct {
+	gorm.Model
+	FlowerID uint   `gorm:"index;not null"`
+	Path     string `gorm:"size:255;not null"`
+}
+
+func (v1FlowerImage) TableName() string { return "flower_images" }
+
+type v1Goods struct {
+	GoodsID        uint      `gorm:"primaryKey;column:goods_id"`
+	CatID          uint      `gorm:"column:cat_id"`
+	GoodsName      string    `gorm:"column:goods_name"`
+	GoodsPrice     float64   `gorm:"type:decimal(10,2);column:goods_price"`
+	GoodsNumber    uint      `gorm:"column:goods_number"`
+	GoodsWeight    uint      `gorm:"column:goods_weight"`
+	GoodsBigLogo   string    `gorm:"column:goods_big_logo"`
+	GoodsSmallLogo string    `gorm:"column:goods_small_logo"`
+	AddTime        time.Time `gorm:"autoCreateTime;column:add_time"`
+	UpdTime        time.Time `gorm:"autoUpdateTime;column:upd_time"`
+	IsPromote      bool      `gorm:"type:tinyint(1);column:is_promote"`
+	HotNumber      uint      `gorm:"column:hot_number"`
+	FlowerID       uint      `gorm:"index;column:flower_id"`
+	MerchantID     uint      `gorm:"column:merchant_id"`
+}
+
+func (v1Goods) TableName() string { return "goods" }
+
+type v1GoodsSearch struct {
+	ID   uint   `gorm:"primaryKey;column:goods_id"`
+	Name string `gorm:"column:goods_name"`
+}
+
+func (v1GoodsSearch) TableName() string { return goodsSearchTable }
+
+type v1GoodsDetail struct {
+	Goods          v1Goods          `gorm:"embedded"`
+	GoodsIntroduce string           `gorm:"type:text;column:goods_introduce"`
+	GoodsState     int              `gorm:"column:goods_state"`
+	IsDel          string           `gorm:"column:is_del"`
+	Pics           []v1GoodsPicture `gorm:"foreignKey:GoodsID"`
+	Attrs          []v1GoodsAttr    `gorm:"foreignKey:GoodsID"`
+}
+
+func (v1GoodsDetail) TableName() string { return "goods_detail" }
+
+type v1GoodsPicture struct {
+	PicsID  uint   `gorm:"primaryKey;column:pics_id"`
+	GoodsID uint   `gorm:"index;column:goods_id"`
+	PicsBig string `gorm:"column:pics_big"`
+	PicsMid string `gorm:"column:pics_mid"`
+	PicsSma string `gorm:"column:pics_sma"`
+}
+
+func (v1GoodsPicture) TableName() string { return "goods_pictures" }
+
+type v1GoodsAttr struct {
+	AttrID    uint    `gorm:"primaryKey;column:attr_id"`
+	GoodsID   uint    `gorm:"index;column:goods_id"`
+	AttrValue string  `gorm:"column:attr_value"`
+	AddPrice  float64 `gorm:"type:decimal(10,2);column:add_price"`
+	AttrName  string  `gorm:"column:attr_name"`
+	AttrSel   string  `gorm:"column:attr_sel"`
+	AttrWrite string  `gorm:"column:attr_write"`
+	AttrVals  string  `gorm:"column:attr_vals"`
+}
+
+func (v1GoodsAttr) TableName() string { return "goods_attrs" }
+
+type v1Category struct {
+	CatID      int    `gorm:"primaryKey;column:cat_id"`
+	CatName    string `gorm:"column:cat_name;size:50;not null"`
+	CatPid     int    `gorm:"column:cat_pid;index"`
+	CatLevel   int    `gorm:"column:cat_level"`
+	CatDeleted bool   `gorm:"column:cat_deleted;index"`
+	CatIcon    string `gorm:"column:cat_icon;size:255"`
+	CatSort    int    `gorm:"column:cat_sort"`
+}
+
+func (v1Category) TableName() string { return "categories" }
+
+type v1ContentSchedule struct {
+	Sort    int `gorm:"index"`
+	Enabled bool
+	StartAt *time.Time
+	EndAt   *time.Time
+}
+
+type v1Banner struct {
+	ID           uint   `gorm:"primaryKey"`
+	ImageSrc     string `gorm:"size:255;not null"`
+	OpenType     string `gorm:"size:20"`
+	GoodsID      int
+	NavigatorURL string            `gorm:"size:255"`
+	Schedule     v1ContentSchedule `gorm:"embedded"`
+}
+
+func (v1Banner) TableName() string { return "banners" }
+
+type v1NavItem struct {
+	ID           uint              `gorm:"primaryKey"`
+	Name         string            `gorm:"size:50;not null"`
+	ImageSrc     string            `gorm:"size:255;not null"`
+	OpenType     string            `gorm:"size:20"`
+	NavigatorURL string            `gorm:"size:255"`
+	Schedule     v1ContentSchedule `gorm:"embedded"`
+}
+
+func (v1NavItem) TableName() string { return "nav_items" }
+
+type v1FloorTitle struct {
+	Name     string `gorm:"size:50"`
+	ImageSrc string `gorm:"size:255"`
+}
+
+type v1Floor struct {
+	ID          uint              `gorm:"primaryKey"`
+	FloorTitle  v1FloorTitle      `gorm:"embedded;embeddedPrefix:title_"`
+	ProductList []v1FloorProduct  `gorm:"foreignKey:FloorID"`
+	Schedule    v1ContentSchedule `gorm:"embedded"`
+}
+
+func (v1Floor) TableName() string { return "floors" }
+
+type v1FloorProduct struct {
+	ID           uint   `gorm:"primaryKey"`
+	FloorID      uint   `gorm:"index;not null"`
+	Name         string `gorm:"size:50"`
+	ImageSrc     string `gorm:"size:255;not null"`
+	ImageWidth   string `gorm:"size:10"`
+	OpenType     string `gorm:"size:20"`
+	NavigatorURL string `gorm:"size:255"`
+	Sort         int
+}
+
+func (v1FloorProduct) TableName() string { return "floor_products" }
+
+type v1User struct {
+	gorm.Model
+	OpenID     string `gorm:"uniqueIndex;size:64;not null"`
+	UnionID    string `gorm:"index;size:64"`
+	SessionKey string `gorm:"size:128"`
+	Nickname   string `gorm:"size:50"`
+	Avatar     string `gorm:"size:255"`
+	Phone      string `gorm:"size:20"`
+	Status     int    `gorm:"default:1"`
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1Address struct {
+	gorm.Model
+	UserID        uint   `gorm:"index;not null"`
+	RecipientName string `gorm:"size:50;not null"`
+	Phone         string `gorm:"size:20;not null"`
+	ProvinceCode  string `gorm:"size:6;not null"`
+	CityCode      string `gorm:"size:6;not null"`
+	DistrictCode  string `gorm:"size:6;not null"`
+	Province      string `gorm:"size:50"`
+	City          string `gorm:"size:50"`
+	District      string `gorm:"size:50"`
+	Detail        string `gorm:"size:200;not null"`
+	IsDefault     bool
+}
+
+func (v1Address) TableName() string { return "addresses" }
+
+type v1CartItem struct {
+	gorm.Model
+	UserID   uint `gorm:"index;not null"`
+	GoodsID  uint `gorm:"index"`
+	AttrID   uint
+	FlowerID uint `gorm:"index"`
+	Quantity int  `gorm:"not null"`
+	Selected bool
+}
+
+func (v1CartItem) TableName() string { return "cart_items" }
+
+type v1Order struct {
+	gorm.Model
+	OrderNo          string     `gorm:"uniqueIndex;size:32;not null"`
+	UserID           uint       `gorm:"index;not null"`
+	MerchantID       uint       `gorm:"index;not null"`
+	Status           string     `gorm:"index;size:20;not null"`
+	TotalAmount      float64    `gorm:"type:decimal(10,2);not null"`
+	ItemCount        int        `gorm:"not null"`
+	Remark           string     `gorm:"size:255"`
+	ExpiresAt        *time.Time `gorm:"index"`
+	DeliveryDate     string     `gorm:"index;size:10"`
+	DeliverySlotID   uint
+	DeliverySlot     string `gorm:"size:11"`
+	RecipientName    string `gorm:"size:50"`
+	RecipientPhone   string `gorm:"size:20"`
+	RecipientAddress string `gorm:"size:255"`
+	CardMessage      string `gorm:"size:500"`
+	PaidAt           *time.Time
+	CompletedAt      *time.Time
+	CancelledAt      *time.Time
+	Items            []v1OrderItem `gorm:"foreignKey:OrderID"`
+}
+
+func (v1Order) TableName() string { return "orders" }
+
+type v1OrderItem struct {
+	ID        uint `gorm:"primaryKey"`
+	OrderID   uint `gorm:"index;not null"`
+	GoodsID   uint
+	AttrID    uint
+	FlowerID  uint
+	Name      string  `gorm:"size:255;not null"`
+	AttrValue string  `gorm:"size:100"`
+	Image     string  `gorm:"size:255"`
+	Price     float64 `gorm:"type:decimal(10,2);not null"`
+	Quantity  int     `gorm:"not null"`
+	Subtotal  float64 `gorm:"type:decimal(10,2);not null"`
+}
+
+func (v1OrderItem) TableName() string { return "order_items" }
+
+type v1OrderStatusHistory struct {
+	ID           uint   `gorm:"primaryKey"`
+	OrderID      uint   `gorm:"index;not null"`
+	FromStatus   string `gorm:"size:20"`
+	ToStatus     string `gorm:"size:20;not null"`
+	OperatorType string `gorm:"size:20;not null"`
+	OperatorID   uint
+	Remark       string `gorm:"size:255"`
+	CreatedAt    time.Time
+}
+
+func (v1OrderStatusHistory) TableName() string { return "order_status_histories" }
+
+type v1Payment struct {
+	gorm.Model
+	OrderID       uint    `gorm:"uniqueIndex;not null"`
+	OrderNo       string  `gorm:"index;size:32;not null"`
+	Provider      string  `gorm:"size:20;not null"`
+	PrepayID      string  `gorm:"size:64"`
+	TransactionID string  `gorm:"size:64"`
+	Amount        float64 `gorm:"type:decimal(10,2);not null"`
+	Status        string  `gorm:"size:20;not null"`
+	PaidAt        *time.Time
+	RefundNo      string `gorm:"size:64"`
+	RefundID      string `gorm:"size:64"`
+	RefundedAt    *time.Time
+}
+
+func (v1Payment) TableName() string { return "payments" }
+
+type v1DeliverySetting struct {
+	gorm.Model
+	MerchantID     uint `gorm:"uniqueIndex;not null"`
+	DailyCapacity  int
+	SameDayCutoff  string `gorm:"size:5"`
+	MaxAdvanceDays int    `gorm:"default:30"`
+}
+
+func (v1DeliverySetting) TableName() string { return "delivery_settings" }
+
+type v1DeliverySlot struct {
+	gorm.Model
+	MerchantID uint   `gorm:"index;not null"`
+	StartTime  string `gorm:"size:5;not null"`
+	EndTime    string `gorm:"size:5;not null"`
+	Capacity   int
+	Enabled    bool
+	Sort       int
+}
+
+func (v1DeliverySlot) TableName() string { return "delivery_slots" }
+
+type v1DeliveryBlackout struct {
+	ID         uint   `gorm:"primaryKey"`
+	MerchantID uint   `gorm:"uniqueIndex:idx_blackout_merchant_date;not null"`
+	Date       string `gorm:"uniqueIndex:idx_blackout_merchant_date;size:10;not null"`
+	Reason     string `gorm:"size:100"`
+}
+
+func (v1DeliveryBlackout) TableName() string { return "delivery_blackouts" }
+
+// ---------------- 版本 4：图片多尺寸版本
+
+type v4FlowerImage struct {
+	gorm.Model
+	FlowerID    uint   `gorm:"index;not null"`
+	Path        string `gorm:"size:255;not null"`
+	BigPath     string `gorm:"size:255"`
+	MidPath     string `gorm:"size:255"`
+	SmaPath     string `gorm:"size:255"`
+	BigWebpPath string `gorm:"size:255"`
+	MidWebpPath string `gorm:"size:255"`
+	SmaWebpPath string `gorm:"size:255"`
+}
+
+func (v4FlowerImage) TableName() string { return "flower_images" }
+
+// ---------------- 版本 5：图片排序
+
+type v5FlowerImage struct {
+	gorm.Model
+	FlowerID uint   `gorm:"index;not null"`
+	Path     string `gorm:"size:255;not null"`
+	Sort     int    `gorm:"not null;default:0"`
+}
+
+func (v5FlowerImage) TableName() string { return "flower_images" }
+
+// ---------------- 版本 6：鲜花规格
+
+// v6Flower 只有主键和规格关联，迁移时不修改鲜花表，用于建立规格表到鲜花表的外键
+type v6Flower struct {
+	ID      uint             `gorm:"primaryKey"`
+	Options []v6FlowerOption `gorm:"foreignKey:FlowerID"`
+	SKUs    []v6FlowerSKU    `gorm:"foreignKey:FlowerID"`
+}
+
+func (v6Flower) TableName() string { return "flowers" }
+
+type v6FlowerOption struct {
+	ID       uint     `gorm:"primaryKey"`
+	FlowerID uint     `gorm:"index;not null"`
+	Name     string   `gorm:"size:20;not null"`
+	Values   []string `gorm:"serializer:json;type:text"`
+	Sort     int      `gorm:"not null;default:0"`
+}
+
+func (v6FlowerOption) TableName() string { return "flower_options" }
+
+type v6FlowerSKU struct {
+	ID        uint     `gorm:"primaryKey"`
+	FlowerID  uint     `gorm:"uniqueIndex:idx_flower_sku_spec;not null"`
+	Spec      string   `gorm:"uniqueIndex:idx_flower_sku_spec;size:100;not null"`
+	Values    []string `gorm:"serializer:json;type:text"`
+	Price     float64  `gorm:"type:decimal(10,2);not null"`
+	Stock     int      `gorm:"not null"`
+	ImageID   uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (v6FlowerSKU) TableName() string { return "flower_skus" }
+
+type v6CartItem struct {
+	gorm.Model
+	UserID   uint `gorm:"index;not null"`
+	GoodsID  uint `gorm:"index"`
+	AttrID   uint
+	FlowerID uint `gorm:"index"`
+	SKUID    uint `gorm:"column:sku_id"`
+	Quantity int  `gorm:"not null"`
+	Selected bool
+}
+
+func (v6CartItem) TableName() string { return "cart_items" }
+
+type v6OrderItem struct {
+	ID        uint `gorm:"primaryKey"`
+	OrderID   uint `gorm:"index;not null"`
+	GoodsID   uint
+	AttrID    uint
+	FlowerID  uint
+	SKUID     uint    `gorm:"column:sku_id"`
+	Name      string  `gorm:"size:255;not null"`
+	AttrValue string  `gorm:"size:100"`
+	Image     string  `gorm:"size:255"`
+	Price     float64 `gorm:"type:decimal(10,2);not null"`
+	Quantity  int     `gorm:"not null"`
+	Subtotal  float64 `gorm:"type:decimal(10,2);not null"`
+}
+
+func (v6OrderItem) TableName() string { return "order_items" }
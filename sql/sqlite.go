@@ -0,0 +1,48 @@
+package sql
+
+import (
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// OpenSQLite 打开 SQLite 数据库，path 为 :memory: 时使用内存库
+// 驱动基于 cgo，编译时需要 CGO_ENABLED=1
+func OpenSQLite(path string) (*gorm.DB, error) {
+	return openSQLite(path, &gorm.Config{})
+}
+
+func openSQLite(path string, gormCfg *gorm.Config) (*gorm.DB, error) {
+	dsn := "file:" + path + "?_foreign_keys=on&_busy_timeout=5000"
+	if path == ":memory:" {
+		dsn = "file::memory:?_foreign_keys=on"
+	}
+	db, err := gorm.Open(sqlite.Open(dsn), gormCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite 同一时间只允许一个写入，使用单连接避免 database is locked；
+	// 内存库每个连接都是独立的库，也必须只用一个连接
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetMaxIdleConns(1)
+	sqlDB.SetConnMaxLifetime(0)
+	return db, nil
+}
+
+// NewMemoryDB 创建一个已执行全部迁移（含内置角色和权限）的 SQLite 内存库，
+// 每次调用都是独立的空库，用于本地调试和不依赖 MySQL 的接口测试
+func NewMemoryDB() (*gorm.DB, error) {
+	db, err := openSQLite(":memory:", &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := Migrate(db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
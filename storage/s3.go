@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config S3 兼容存储配置，可对接 AWS S3、阿里云 OSS、腾讯云 COS 或本地 MinIO
+type S3Config struct {
+	Endpoint  string // 不带协议，例如 127.0.0.1:9000
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Region    string
+	UseSSL    bool
+}
+
+// S3 S3 兼容存储，使用路径风格访问 bucket
+type S3 struct {
+	client  *minio.Client
+	bucket  string
+	baseURL string
+}
+
+// NewS3 创建 S3 存储并确认 bucket 存在
+func NewS3(cfg S3Config, baseURL string) (*S3, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ok, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("check bucket %s: %w", cfg.Bucket, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("bucket %s does not exist", cfg.Bucket)
+	}
+	return &S3{client: client, bucket: cfg.Bucket, baseURL: baseURL}, nil
+}
+
+// Put 上传对象
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+// Get 读取对象，先 Stat 一次以便区分对象不存在
+func (s *S3) Get(ctx context.Context, key string) (io.ReadSeekCloser, *Object, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, s.translate(err)
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, nil, s.translate(err)
+	}
+	return obj, &Object{
+		Key:         key,
+		Size:        info.Size,
+		ContentType: info.ContentType,
+		ModTime:     info.LastModified,
+		ETag:        `"` + info.ETag + `"`,
+	}, nil
+}
+
+// Delete 删除对象，S3 删除不存在的对象同样返回成功
+func (s *S3) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// URL 访问地址
+func (s *S3) URL(key string) string {
+	return joinURL(s.baseURL, key)
+}
+
+// List 遍历 bucket 中以 prefix 开头的对象
+func (s *S3) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // 提前结束时停止后台的分页请求
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return info.Err
+		}
+		obj := Object{Key: info.Key, Size: info.Size, ContentType: info.ContentType, ModTime: info.LastModified, ETag: `"` + info.ETag + `"`}
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// translate 把对象不存在的错误转换为 ErrNotFound
+func (s *S3) translate(err error) error {
+	resp := minio.ToErrorResponse(err)
+	if resp.Code == "NoSuchKey" || resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return err
+}
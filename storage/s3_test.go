@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testBucket = "flowers"
+
+// fakeS3 内存中的 S3 服务，只实现 S3 存储用到的接口
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+func (o fakeObject) etag() string {
+	sum := md5.Sum(o.data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+type listBucketResult struct {
+	XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name        string
+	Prefix      string
+	KeyCount    int
+	MaxKeys     int
+	IsTruncated bool
+	Contents    []listEntry
+}
+
+type listEntry struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != testBucket {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if key == "" {
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			f.list(w, r.URL.Query().Get("prefix"))
+		default:
+			writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := readS3Body(r)
+		if err != nil {
+			writeS3Error(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		obj := fakeObject{data: data, contentType: r.Header.Get("Content-Type"), modTime: time.Now().UTC()}
+		f.objects[key] = obj
+		w.Header().Set("ETag", obj.etag())
+	case http.MethodGet, http.MethodHead:
+		obj, ok := f.objects[key]
+		if !ok {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", obj.etag())
+		w.Header().Set("Content-Type", obj.contentType)
+		http.ServeContent(w, r, "", obj.modTime, bytes.NewReader(obj.data))
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	result := listBucketResult{Name: testBucket, Prefix: prefix, MaxKeys: 1000}
+	for key, obj := range f.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		result.Contents = append(result.Contents, listEntry{
+			Key:          key,
+			LastModified: obj.modTime.Format("2006-01-02T15:04:05.000Z"),
+			ETag:         obj.etag(),
+			Size:         int64(len(obj.data)),
+			StorageClass: "STANDARD",
+		})
+	}
+	sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// readS3Body 读取上传内容，非 TLS 连接下 minio 使用 aws-chunked 分块签名上传
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	var buf bytes.Buffer
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return buf.Bytes(), nil
+		}
+		if _, err := io.CopyN(&buf, br, size); err != nil {
+			return nil, err
+		}
+		if _, err := br.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+	}
+}
+
+func newTestS3(t *testing.T) *S3 {
+	t.Helper()
+	srv := httptest.NewServer(&fakeS3{objects: make(map[string]fakeObject)})
+	t.Cleanup(srv.Close)
+	s, err := NewS3(S3Config{
+		Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+		AccessKey: "access",
+		SecretKey: "secret",
+		Bucket:    testBucket,
+		Region:    "us-east-1",
+	}, "http://img.test/media")
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	return s
+}
+
+func TestS3MissingBucket(t *testing.T) {
+	srv := httptest.NewServer(&fakeS3{objects: make(map[string]fakeObject)})
+	defer srv.Close()
+	_, err := NewS3(S3Config{Endpoint: strings.TrimPrefix(srv.URL, "http://"), AccessKey: "a", SecretKey: "b", Bucket: "missing", Region: "us-east-1"}, "")
+	if err == nil {
+		t.Fatal("NewS3 succeeded for a missing bucket")
+	}
+}
+
+func TestS3PutGetDelete(t *testing.T) {
+	s := newTestS3(t)
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "flowers/a.jpg", strings.NewReader("hello world"), 11, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	f, obj, err := s.Get(ctx, "flowers/a.jpg")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if obj.Size != 11 || obj.ContentType != "image/jpeg" || obj.ETag == `""` {
+		t.Fatalf("object = %+v", obj)
+	}
+	if _, err := f.Seek(6, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != "world" {
+		t.Fatalf("read after seek = %q, %v", data, err)
+	}
+
+	if err := s.Delete(ctx, "flowers/a.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, err := s.Get(ctx, "flowers/a.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after delete: %v, want ErrNotFound", err)
+	}
+	// 删除不存在的对象不报错
+	if err := s.Delete(ctx, "flowers/a.jpg"); err != nil {
+		t.Fatalf("Delete missing: %v", err)
+	}
+}
+
+func TestS3List(t *testing.T) {
+	s := newTestS3(t)
+	ctx := context.Background()
+	for _, key := range []string{"flowers/a.jpg", "flowers/b.webp", "legacy.jpg"} {
+		if err := s.Put(ctx, key, strings.NewReader(key), int64(len(key)), "image/jpeg"); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	var keys []string
+	err := s.List(ctx, "flowers/", func(obj Object) error {
+		if obj.Size == 0 || obj.ModTime.IsZero() {
+			t.Errorf("object %+v missing size or mod time", obj)
+		}
+		keys = append(keys, obj.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if strings.Join(keys, ",") != "flowers/a.jpg,flowers/b.webp" {
+		t.Fatalf("keys = %v", keys)
+	}
+
+	keys = nil
+	if err := s.List(ctx, "", func(obj Object) error { keys = append(keys, obj.Key); return nil }); err != nil {
+		t.Fatalf("List all: %v", err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("keys = %v, want all 3", keys)
+	}
+
+	// 回调返回错误时停止遍历
+	stop := errors.New("stop")
+	var n int
+	err = s.List(ctx, "", func(Object) error { n++; return stop })
+	if !errors.Is(err, stop) || n != 1 {
+		t.Fatalf("List stop = %v after %d objects", err, n)
+	}
+}
+
+func TestS3RejectsInvalidKey(t *testing.T) {
+	s := newTestS3(t)
+	ctx := context.Background()
+	if err := s.Put(ctx, "../escape.jpg", strings.NewReader("x"), 1, "image/jpeg"); err == nil {
+		t.Fatal("Put accepted a key outside the bucket")
+	}
+	if err := s.Delete(ctx, "../escape.jpg"); err == nil {
+		t.Fatal("Delete accepted a key outside the bucket")
+	}
+}
@@ -0,0 +1,89 @@
+// Package storage 封装上传文件的存储，提供本地磁盘和 S3 兼容两种实现
+// 数据库中只保存对象的 key（如 flowers/xxx.jpg），访问地址在返回给前端时由 URL 生成
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Object 已存储对象的元数据
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	ETag        string // 带双引号，可直接用于 ETag 响应头
+}
+
+// Storage 文件存储
+type Storage interface {
+	// Put 写入对象，key 已存在时覆盖
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象，调用方负责关闭返回的文件
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, *Object, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// URL 对象的访问地址
+	URL(key string) string
+	// List 遍历 key 以 prefix 开头的全部对象，fn 返回错误时停止遍历
+	List(ctx context.Context, prefix string, fn func(Object) error) error
+}
+
+var (
+	// ErrNotFound 对象不存在
+	ErrNotFound = errors.New("storage: object not found")
+	// ErrInvalidKey key 为空、是绝对路径或包含 ..
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// CleanKey 校验 key，只允许不含 .. 的相对路径，分隔符为 /
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) || strings.ContainsRune(key, 0) {
+		return "", ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+	return path.Clean(key), nil
+}
+
+// joinURL 拼接访问地址前缀和 key，key 的每一段分别转义，文件名中的空格、# 和 ? 等不会破坏地址
+func joinURL(baseURL, key string) string {
+	if key == "" {
+		return ""
+	}
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.TrimRight(baseURL, "/") + "/" + strings.Join(parts, "/")
+}
+
+// Config 存储配置
+type Config struct {
+	Driver  string // local 或 s3
+	Dir     string // local：文件保存目录
+	BaseURL string // 访问地址前缀，例如 /media 或 https://cdn.example.com/flowers
+	S3      S3Config
+}
+
+// New 按配置创建存储
+func New(cfg Config) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocal(cfg.Dir, cfg.BaseURL)
+	case "s3":
+		return NewS3(cfg.S3, cfg.BaseURL)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
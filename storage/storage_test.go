@@ -0,0 +1,19 @@
+package storage
+
+import "testing"
+
+func TestJoinURL(t *testing.T) {
+	tests := []struct {
+		base, key, want string
+	}{
+		{"/media", "flowers/a.jpg", "/media/flowers/a.jpg"},
+		{"https://cdn.example.com/flowers/", "a.jpg", "https://cdn.example.com/flowers/a.jpg"},
+		{"/media", "flowers/红 玫瑰#1?.jpg", "/media/flowers/%E7%BA%A2%20%E7%8E%AB%E7%91%B0%231%3F.jpg"},
+		{"/media", "", ""},
+	}
+	for _, tt := range tests {
+		if got := joinURL(tt.base, tt.key); got != tt.want {
+			t.Errorf("joinURL(%q, %q) = %q, want %q", tt.base, tt.key, got, tt.want)
+		}
+	}
+}
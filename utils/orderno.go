@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// GenerateOrderNo 生成订单号：14 位时间 + 6 位随机数
+func GenerateOrderNo() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		n = big.NewInt(time.Now().UnixNano() % 1000000)
+	}
+	return fmt.Sprintf("%s%06d", time.Now().Format("20060102150405"), n.Int64())
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordCost bcrypt 计算成本，低于该值的哈希会在登录时自动升级
+const PasswordCost = 12
+
+// HashPassword 使用 bcrypt 生成密码哈希，盐值随机生成并保存在哈希串中
+func HashPassword(plainpwd string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plainpwd), PasswordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// isBcryptHash 判断存储的密码是否为 bcrypt 哈希
+func isBcryptHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// isMD5Hash 判断存储的密码是否为 32 位十六进制 MD5 摘要
+func isMD5Hash(stored string) bool {
+	if len(stored) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(stored)
+	return err == nil
+}
+
+// VerifyPassword 校验密码
+// stored 为数据库中的密码字段，salt 仅用于旧版 MakePassword 生成的 MD5 密码。
+// needRehash 为 true 表示密码仍是明文/MD5 或 bcrypt 成本过低，校验通过后应重新哈希保存。
+func VerifyPassword(plainpwd, stored, salt string) (ok bool, needRehash bool) {
+	if isBcryptHash(stored) {
+		if bcrypt.CompareHashAndPassword([]byte(stored), []byte(plainpwd)) != nil {
+			return false, false
+		}
+		cost, err := bcrypt.Cost([]byte(stored))
+		return true, err != nil || cost < PasswordCost
+	}
+
+	// 旧版 MD5 密码，不能再按明文比较，否则泄露的摘要本身就能登录
+	if isMD5Hash(stored) {
+		if subtle.ConstantTimeCompare([]byte(MakePassword(plainpwd, salt)), []byte(strings.ToLower(stored))) == 1 {
+			return true, true
+		}
+		return false, false
+	}
+	// 旧版明文密码
+	if subtle.ConstantTimeCompare([]byte(plainpwd), []byte(stored)) == 1 {
+		return true, true
+	}
+	return false, false
+}
@@ -0,0 +1,33 @@
+package utils
+
+import "testing"
+
+func TestVerifyPassword(t *testing.T) {
+	hash, err := HashPassword("secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md5Stored := MakePassword("secret123", "salt")
+
+	tests := []struct {
+		name       string
+		plain      string
+		stored     string
+		salt       string
+		ok, rehash bool
+	}{
+		{"bcrypt", "secret123", hash, "", true, false},
+		{"bcrypt wrong", "wrong", hash, "", false, false},
+		{"md5", "secret123", md5Stored, "salt", true, true},
+		{"md5 wrong", "wrong", md5Stored, "salt", false, false},
+		{"md5 digest as password", md5Stored, md5Stored, "salt", false, false},
+		{"plaintext", "secret123", "secret123", "", true, true},
+		{"plaintext wrong", "wrong", "secret123", "", false, false},
+	}
+	for _, tt := range tests {
+		ok, rehash := VerifyPassword(tt.plain, tt.stored, tt.salt)
+		if ok != tt.ok || rehash != tt.rehash {
+			t.Errorf("%s: VerifyPassword = %v, %v; want %v, %v", tt.name, ok, rehash, tt.ok, tt.rehash)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 令牌角色
+const (
+	RoleMerchant = "merchant"
+	RoleAdmin    = "admin"
+	RoleUser     = "user"
+)
+
+// Claims 访问令牌中携带的身份信息
+type Claims struct {
+	ID      uint   `json:"id"`            // 商家/管理员/买家ID
+	Role    string `json:"role"`          // 令牌角色，区分商家端、管理端和买家
+	Version uint   `json:"ver,omitempty"` // 令牌版本，与账号当前版本不一致时令牌失效
+	jwt.RegisteredClaims
+}
+
+// GenerateToken 签发 HS256 访问令牌
+func GenerateToken(secret string, id uint, role string, ttl time.Duration, version uint) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		ID:      id,
+		Role:    role,
+		Version: version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
+// ParseToken 校验签名和有效期并返回令牌信息
+func ParseToken(secret, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}